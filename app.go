@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"github.com/shubhambadola/VidFetch/downloader"
 	"github.com/shubhambadola/VidFetch/storage"
@@ -12,24 +14,105 @@ import (
 
 // App struct
 type App struct {
-	ctx        context.Context
-	downloader *downloader.Downloader
-	history    *storage.History
+	ctx           context.Context
+	downloader    *downloader.Downloader
+	history       storage.HistoryStore
+	queue         *storage.Queue
+	escalation    *storage.Escalation
+	subscriptions *storage.Subscriptions
+	archive       *downloader.Archive
+
+	historyErr error              // Opening history.db failed, reported once the window is up
+	stop       context.CancelFunc // Stops the workers and scheduled syncs
 }
 
+// historyOpenAttempts bounds how long startup waits for history.db, with
+// the one second lock timeout of each attempt and a second between them
+const historyOpenAttempts = 5
+
 // NewApp creates a new App application struct
 func NewApp() *App {
-	// Initialize history
-	hist, err := storage.NewHistory("history.json")
+	// Initialize history database, importing history.json on first run.
+	// The file may be locked briefly by a closing instance. If it stays
+	// locked, this session keeps its history in memory only and says so,
+	// rather than writing records the database would never see.
+	var hist storage.HistoryStore
+	hist, err := storage.OpenHistoryDB("history.db", "history.json")
+	for attempt := 1; err != nil && attempt < historyOpenAttempts; attempt++ {
+		log.Printf("Failed to open history database, retrying: %v", err)
+		time.Sleep(time.Second)
+		hist, err = storage.OpenHistoryDB("history.db", "history.json")
+	}
+	historyErr := err
+	if historyErr != nil {
+		log.Printf("Failed to open history database, keeping this session's history in memory: %v", historyErr)
+		hist, _ = storage.NewHistory("")
+	}
+
+	// Apply the retention policy to what accumulated since the last run
+	if pruned, err := hist.Prune(); err != nil {
+		log.Printf("Failed to prune history: %v", err)
+	} else if pruned > 0 {
+		log.Printf("Pruned %d history entries", pruned)
+	}
+
+	// Initialize persisted queue
+	queue, err := storage.NewQueue("queue.json")
 	if err != nil {
-		log.Printf("Failed to load history: %v", err)
-		// valid to continue with empty history
-		hist, _ = storage.NewHistory("history.json")
+		log.Printf("Failed to load queue: %v", err)
+	}
+
+	// Initialize anti-blocking memory
+	escalation, err := storage.NewEscalation("escalation.json")
+	if err != nil {
+		log.Printf("Failed to load escalation settings: %v", err)
+	}
+
+	// Initialize channel/playlist subscriptions
+	subs, err := storage.NewSubscriptions("subscriptions.json")
+	if err != nil {
+		log.Printf("Failed to load subscriptions: %v", err)
+	}
+
+	// Initialize the download archive (yt-dlp --download-archive format),
+	// seeded with videos downloaded before it existed
+	archive, err := downloader.NewArchive("archive.txt")
+	if err != nil {
+		log.Printf("Failed to load download archive: %v", err)
+	} else if archive.Len() == 0 {
+		for _, key := range hist.ArchiveKeys() {
+			archive.Add(key)
+		}
 	}
 
 	app := &App{
-		downloader: downloader.NewDownloader(3), // Max 3 concurrent
-		history:    hist,
+		downloader:    downloader.NewDownloader(3), // Max 3 concurrent
+		history:       hist,
+		queue:         queue,
+		escalation:    escalation,
+		subscriptions: subs,
+		archive:       archive,
+		historyErr:    historyErr,
+	}
+
+	// Skip, re-download or link videos downloaded before
+	app.downloader.Archive = archive
+	app.downloader.FindDownloaded = hist.Find
+
+	// Start escalated jobs at the level that worked for their site before
+	app.downloader.SetSiteLevels(escalation.GetLevels())
+	app.downloader.SetProxies(escalation.GetProxies())
+	app.downloader.OnSiteLevel = func(site string, level int) {
+		if err := app.escalation.SetLevel(site, level); err != nil {
+			log.Printf("Failed to save escalation level: %v", err)
+		}
+	}
+
+	// Persist every state change so unfinished jobs survive a restart
+	app.downloader.OnChange = func(dl *downloader.Download) {
+		if err := app.queue.Update(*dl); err != nil {
+			log.Printf("Failed to save queue: %v", err)
+		}
 	}
 
 	// Setup callback
@@ -37,11 +120,18 @@ func NewApp() *App {
 		// Save to history
 		if err := app.history.Add(*dl); err != nil {
 			log.Printf("Failed to save history: %v", err)
+		} else if app.ctx != nil {
+			runtime.EventsEmit(app.ctx, "history-updated")
+		}
+
+		// Subscriptions re-queue entries that did not complete on the next sync
+		if err := app.subscriptions.Finished(*dl); err != nil {
+			log.Printf("Failed to save subscriptions: %v", err)
 		}
 
 		// Emit event to frontend if context is available
 		// Only emit success event if actually completed
-		if app.ctx != nil && dl.Status == "completed" {
+		if app.ctx != nil && dl.Status == downloader.StatusCompleted {
 			runtime.EventsEmit(app.ctx, "download-complete", dl)
 		}
 	}
@@ -53,10 +143,26 @@ func NewApp() *App {
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	// Workers get their own context so shutdown can stop them first
+	ctx, a.stop = context.WithCancel(ctx)
 
 	// Start downloader workers
 	a.downloader.Start(ctx)
 
+	// Forward lifecycle and progress events to the frontend
+	events, unsubscribe := a.downloader.Subscribe()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				runtime.EventsEmit(ctx, "download-event", ev)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	// Ensure yt-dlp is installed
 	// Ensure yt-dlp is installed and get path
 	go func() {
@@ -79,9 +185,41 @@ func (a *App) startup(ctx context.Context) {
 				}
 			}()
 		}
+
+		// Re-queue jobs left unfinished by the previous run
+		a.downloader.Restore(a.queue.Get())
+
+		// Check subscriptions for new entries from now on
+		a.subscriptions.Schedule(ctx, a.downloader)
 	}()
 }
 
+// domReady is called once the frontend has loaded, when dialogs can be shown
+func (a *App) domReady(ctx context.Context) {
+	if a.historyErr != nil {
+		runtime.MessageDialog(ctx, runtime.MessageDialogOptions{
+			Type:  runtime.ErrorDialog,
+			Title: "History unavailable",
+			Message: fmt.Sprintf("history.db could not be opened: %v\n\n"+
+				"Another VidFetch window or a cli command is probably using it. Downloads of this session "+
+				"are kept in memory only and will be missing from the history; close the other program and "+
+				"restart VidFetch to record them.", a.historyErr),
+		})
+	}
+}
+
+// shutdown is called when the app is closing. The workers are stopped and
+// the last OnComplete callbacks waited for before the history is closed.
+func (a *App) shutdown(ctx context.Context) {
+	if a.stop != nil {
+		a.stop()
+	}
+	a.downloader.Wait()
+	if err := a.history.Close(); err != nil {
+		log.Printf("Failed to close history: %v", err)
+	}
+}
+
 // DownloadVideo is the method exposed to the frontend
 func (a *App) DownloadVideo(url string) (string, error) {
 	// Create default options for now (will expand later)
@@ -119,18 +257,268 @@ func (a *App) DownloadVideoWithOptions(url string, options downloader.DownloadOp
 	return fmt.Sprintf("Download queued: %s", id), nil
 }
 
+// DownloadPlaylistEntries queues only the chosen entries (1-based indices, as
+// numbered in GetVideoInfo's Entries) of a playlist or channel
+func (a *App) DownloadPlaylistEntries(url string, indices []int, options downloader.DownloadOptions) (string, error) {
+	if len(indices) == 0 {
+		return "", fmt.Errorf("no playlist entries selected")
+	}
+	options.PlaylistItems = downloader.PlaylistItems(indices)
+	return a.DownloadVideoWithOptions(url, options)
+}
+
+// GetVideoInfo fetches title, thumbnail, duration etc. for a URL without
+// downloading it; playlists and channels also list their entries
+func (a *App) GetVideoInfo(url string) (*downloader.VideoInfo, error) {
+	return a.downloader.FetchInfo(a.ctx, url, downloader.DownloadOptions{})
+}
+
+// ListFormats returns the formats (resolutions, codecs, sizes) available for a URL
+func (a *App) ListFormats(url string) ([]downloader.Format, error) {
+	return a.downloader.ListFormats(a.ctx, url, downloader.DownloadOptions{})
+}
+
+// CancelDownload stops a download and discards its partial files
+func (a *App) CancelDownload(id string) error {
+	return a.downloader.Cancel(id)
+}
+
+// PauseDownload stops a download but keeps its partial files for ResumeDownload
+func (a *App) PauseDownload(id string) error {
+	return a.downloader.Pause(id)
+}
+
+// ResumeDownload re-queues a paused download
+func (a *App) ResumeDownload(id string) error {
+	return a.downloader.Resume(id)
+}
+
+// GetProxyList returns the proxies used as the last escalation steps
+func (a *App) GetProxyList() []string {
+	return a.escalation.GetProxies()
+}
+
+// SetProxyList replaces the proxies used as the last escalation steps
+func (a *App) SetProxyList(proxies []string) error {
+	a.downloader.SetProxies(proxies)
+	return a.escalation.SetProxies(proxies)
+}
+
+// GetSiteLevels returns the escalation level remembered per site
+func (a *App) GetSiteLevels() map[string]int {
+	return a.downloader.SiteLevels()
+}
+
+// AddSubscription subscribes to a channel or playlist, checked every
+// intervalMinutes (0 for the default). With onlyNew, entries published so far
+// are skipped; otherwise they are queued by the first sync.
+func (a *App) AddSubscription(url string, options downloader.DownloadOptions, intervalMinutes int, onlyNew bool) (storage.Subscription, error) {
+	if options.OutputDir == "" {
+		options.OutputDir = "./downloads"
+	}
+	if options.OutputTemplate == "" {
+		options.OutputTemplate = "%(title)s.%(ext)s"
+	}
+	if len(options.SubtitleLangs) == 0 {
+		options.SubtitleLangs = []string{"all"}
+	}
+
+	sub, err := a.subscriptions.Add(url, options, intervalMinutes)
+	if err != nil {
+		return sub, err
+	}
+	if onlyNew {
+		if err := a.subscriptions.MarkExisting(a.ctx, a.downloader, sub.ID); err != nil {
+			a.subscriptions.Remove(sub.ID)
+			return sub, err
+		}
+	} else if _, err := a.subscriptions.Sync(a.ctx, a.downloader, sub.ID); err != nil {
+		log.Printf("First sync of %s failed: %v", url, err)
+	}
+	return sub, nil
+}
+
+// GetSubscriptions returns all subscriptions
+func (a *App) GetSubscriptions() []storage.Subscription {
+	return a.subscriptions.Get()
+}
+
+// RemoveSubscription stops checking a subscription; queued entries keep downloading
+func (a *App) RemoveSubscription(id string) error {
+	return a.subscriptions.Remove(id)
+}
+
+// SyncSubscription checks a subscription now and returns how many entries were queued
+func (a *App) SyncSubscription(id string) (int, error) {
+	ids, err := a.subscriptions.Sync(a.ctx, a.downloader, id)
+	return len(ids), err
+}
+
+// ImportArchive merges an existing yt-dlp archive file, returning how many
+// videos were new
+func (a *App) ImportArchive(path string) (int, error) {
+	if a.archive == nil {
+		return 0, fmt.Errorf("download archive is not available")
+	}
+	return a.archive.Import(path)
+}
+
 // GetHistory returns completed downloads
 func (a *App) GetHistory() []downloader.Download {
 	return a.history.Get()
 }
 
+// QueryHistory returns a page of finished downloads filtered by status,
+// platform, date range and text, in the order q.Sort asks for
+func (a *App) QueryHistory(q storage.Query) (storage.Page, error) {
+	return a.history.Query(q)
+}
+
+// DeleteHistory removes a finished download from the history and, with
+// deleteFiles, its media, subtitle and thumbnail files from disk
+func (a *App) DeleteHistory(id string, deleteFiles bool) error {
+	dl, err := a.history.Delete(id)
+	if err != nil {
+		return err
+	}
+	if deleteFiles {
+		return storage.RemoveFiles(dl)
+	}
+	return nil
+}
+
+// Redownload queues a new download of a history entry with the options it
+// was downloaded with
+func (a *App) Redownload(id string) (string, error) {
+	dl := a.history.Lookup(id)
+	if dl == nil {
+		return "", fmt.Errorf("history entry not found: %s", id)
+	}
+	opts := dl.Options
+	opts.Duplicates = downloader.DuplicateRedownload // It is archived by now
+	return a.downloader.QueueDownload(dl.URL, opts), nil
+}
+
+// VerifyLibrary checks that the files of every completed download still
+// exist unchanged, emitting "verify-progress" events (done, total), and
+// reports the downloads with moved or broken files
+func (a *App) VerifyLibrary(quick bool) (storage.VerifyReport, error) {
+	return storage.Verify(a.ctx, a.history, storage.VerifyOptions{
+		Quick: quick,
+		OnProgress: func(done int, total int) {
+			runtime.EventsEmit(a.ctx, "verify-progress", done, total)
+		},
+	})
+}
+
+// RequeueBroken downloads the broken entries of a VerifyLibrary report
+// again, setting their damaged files aside first. Returns the new download IDs.
+func (a *App) RequeueBroken(results []storage.VerifyResult) ([]string, error) {
+	var ids []string
+	for _, result := range results {
+		if !result.Broken {
+			continue
+		}
+		if err := storage.SetAsideBroken(result); err != nil {
+			return ids, err
+		}
+		id, err := a.Redownload(result.ID)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ExportHistory writes the downloads matching q to path as JSON Lines, or
+// as CSV with the given columns when path ends in .csv. Returns how many were
+// written.
+func (a *App) ExportHistory(path string, columns []string, q storage.Query) (int, error) {
+	page, err := a.history.Query(q)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	err = storage.Export(f, page.Downloads, storage.FormatForPath(path), columns)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return len(page.Downloads), nil
+}
+
+// GetExportColumns returns every column a CSV export can include
+func (a *App) GetExportColumns() []string {
+	return storage.Columns()
+}
+
+// ImportHistory merges a JSON Lines or CSV export into the history,
+// deduplicating by storage.MergeByID or storage.MergeByVideo. Returns how
+// many downloads were added.
+func (a *App) ImportHistory(path string, by string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	downloads, err := storage.ReadExport(f, storage.FormatForPath(path))
+	if err != nil {
+		return 0, err
+	}
+	return storage.Merge(a.history, downloads, by)
+}
+
+// GetStats aggregates the downloads matching q (e.g. a date range or
+// platform) by day, week and platform, with the largest files and most
+// common errors
+func (a *App) GetStats(q storage.Query) (storage.Stats, error) {
+	page, err := a.history.Query(q)
+	if err != nil {
+		return storage.Stats{}, err
+	}
+	return storage.ComputeStats(page.Downloads, 10), nil
+}
+
+// GetRetention returns the history retention policy
+func (a *App) GetRetention() storage.Retention {
+	return a.history.Retention()
+}
+
+// SetRetention saves the history retention policy and prunes the history
+// with it right away
+func (a *App) SetRetention(r storage.Retention) error {
+	if err := a.history.SetRetention(r); err != nil {
+		return err
+	}
+	_, err := a.history.Prune()
+	return err
+}
+
+// PruneHistory applies the retention policy and returns how many entries
+// were dropped
+func (a *App) PruneHistory() (int, error) {
+	return a.history.Prune()
+}
+
 // GetQueue returns active/pending downloads
 func (a *App) GetQueue() []downloader.Download {
 	return a.downloader.GetAllDownloads()
 }
 
+// GetCollectionItems returns the entry downloads of a playlist or channel download
+func (a *App) GetCollectionItems(id string) []downloader.Download {
+	return a.downloader.Children(id)
+}
+
 // GetProgress exposed to frontend
-func (a *App) GetProgress(id string) (float64, string, string) {
+func (a *App) GetProgress(id string) downloader.Progress {
 	return a.downloader.GetProgress(id)
 }
 
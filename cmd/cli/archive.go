@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// runArchive implements `cli archive import <file>`: merge an existing yt-dlp
+// --download-archive file into VidFetch's archive
+func runArchive(args []string) {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	archivePath := fs.String("archive", "archive.txt", "Download archive to import into (shared with the desktop app when run from its directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli archive import [flags] <yt-dlp archive file>\n")
+		fs.PrintDefaults()
+	}
+	if len(args) > 0 && isHelp(args[0]) {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return
+	}
+	if len(args) == 0 || args[0] != "import" {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	fs.Parse(args[1:])
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	archive, err := downloader.NewArchive(*archivePath)
+	if err != nil {
+		log.Fatalf("Failed to open download archive: %v", err)
+	}
+	added, err := archive.Import(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to import %s: %v", fs.Arg(0), err)
+	}
+	fmt.Printf("Imported %d new videos, %d in archive\n", added, archive.Len())
+}
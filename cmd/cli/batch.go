@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// readBatchFile reads one URL per line from path ("-" for stdin), skipping
+// blank lines and comments starting with #, ; or ] as yt-dlp does
+func readBatchFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsAny(line[:1], "#;]") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a
+// pipe or file
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// batchView shows the progress and outcome of a batch as its events arrive
+type batchView interface {
+	queued(jobs []downloader.Download, workers int)
+	event(ev downloader.Event, job bool) // job is false for playlist entries
+	tick()
+	done(jobs []downloader.Download, elapsed time.Duration, interrupted bool)
+}
+
+// outcome sorts a finished (or paused) job into the summary; a playlist
+// with failed entries counts as failed
+func outcome(dl downloader.Download) string {
+	switch {
+	case dl.Status == downloader.StatusCompleted && dl.ItemsFailed == 0:
+		return downloader.StatusCompleted
+	case dl.Status == downloader.StatusSkipped:
+		return downloader.StatusSkipped
+	case dl.Status == downloader.StatusPaused || dl.Status == downloader.StatusCancelled:
+		return downloader.StatusPaused
+	}
+	return downloader.StatusFailed
+}
+
+// batchDisplay redraws one progress line per job on a terminal, and prints a
+// line per finished job otherwise
+type batchDisplay struct {
+	dlr   *downloader.Downloader
+	jobs  map[string]downloader.Download // Latest state of each job
+	ids   []string
+	tty   bool
+	lines int // Lines drawn last time, to move back over
+}
+
+func (b *batchDisplay) queued(jobs []downloader.Download, workers int) {
+	b.jobs = make(map[string]downloader.Download, len(jobs))
+	for _, dl := range jobs {
+		b.ids = append(b.ids, dl.ID)
+		b.jobs[dl.ID] = dl
+	}
+	fmt.Printf("Downloading %d URLs, %d at a time\n", len(jobs), workers)
+}
+
+func (b *batchDisplay) event(ev downloader.Event, job bool) {
+	if !job {
+		return // Playlist entries show through their parent
+	}
+	dl := ev.Download
+	b.jobs[dl.ID] = dl
+	if b.tty {
+		return
+	}
+	switch ev.Type {
+	case downloader.EventCompleted, downloader.EventSkipped, downloader.EventFailed, downloader.EventPaused, downloader.EventCancelled:
+	default:
+		return
+	}
+	switch outcome(dl) {
+	case downloader.StatusCompleted:
+		fmt.Printf("Downloaded: %s\n", jobName(dl))
+	case downloader.StatusSkipped:
+		fmt.Printf("Already downloaded: %s\n", jobName(dl))
+	case downloader.StatusPaused:
+		fmt.Printf("%s: %s\n", dl.Status, jobName(dl))
+	default:
+		fmt.Printf("%s: %s: %s\n", dl.Status, jobName(dl), dl.Error)
+	}
+}
+
+func (b *batchDisplay) tick() {
+	if !b.tty {
+		return
+	}
+	if b.lines > 0 {
+		fmt.Printf("\033[%dA", b.lines)
+	}
+	width := len(fmt.Sprint(len(b.ids)))
+	for i, id := range b.ids {
+		dl := b.jobs[id]
+		p := b.dlr.GetProgress(id)
+		detail := p.Label()
+		if p.Speed != "" && !dl.Finished() {
+			detail += " " + p.Speed
+			if p.ETA != "" {
+				detail += " ETA " + p.ETA
+			}
+		}
+		if dl.Error != "" && dl.Status == downloader.StatusFailed {
+			detail = dl.Error
+		}
+		fmt.Printf("\033[2K[%*d/%d] %5.1f%% %-11s %s | %s\n", width, i+1, len(b.ids), p.Progress*100, p.Status, truncate(jobName(dl), 50), detail)
+	}
+	b.lines = len(b.ids)
+}
+
+func (b *batchDisplay) done(jobs []downloader.Download, elapsed time.Duration, interrupted bool) {
+	b.tick()
+
+	counts := make(map[string]int)
+	var failures []downloader.Download
+	for _, dl := range jobs {
+		o := outcome(dl)
+		counts[o]++
+		if o == downloader.StatusFailed {
+			failures = append(failures, dl)
+		}
+	}
+
+	fmt.Printf("\n%d URLs in %v: %d completed, %d already downloaded, %d failed", len(jobs), elapsed.Round(time.Second),
+		counts[downloader.StatusCompleted], counts[downloader.StatusSkipped], counts[downloader.StatusFailed])
+	if counts[downloader.StatusPaused] > 0 {
+		fmt.Printf(", %d paused or cancelled", counts[downloader.StatusPaused])
+	}
+	fmt.Println()
+	for _, dl := range failures {
+		fmt.Printf("Failed: %s: %s\n", dl.URL, dl.Error)
+	}
+	if interrupted && counts[downloader.StatusPaused] > 0 {
+		fmt.Println("Partial files kept. Run the same command again to resume.")
+	}
+}
+
+// jobName is the title of a job, or its URL until the title is known
+func jobName(dl downloader.Download) string {
+	if dl.Title == "" {
+		return dl.URL
+	}
+	return dl.Title
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// getMany downloads URLs through the worker pool, showing their progress in
+// view. Returns the exit code: 1 when any job failed and 130 when
+// interrupted.
+func getMany(ctx context.Context, dlr *downloader.Downloader, urls []string, opts downloader.DownloadOptions, workers int, view batchView) int {
+	start := time.Now()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	events, unsubscribe := dlr.Subscribe()
+	defer unsubscribe()
+	dlr.Start(ctx)
+
+	var ids []string
+	jobs := make(map[string]downloader.Download)
+	var queued []downloader.Download
+	for _, url := range urls {
+		id := dlr.QueueDownload(url, opts)
+		ids = append(ids, id)
+		jobs[id] = downloader.Download{ID: id, URL: url, Status: downloader.StatusPending}
+		queued = append(queued, jobs[id])
+	}
+	view.queued(queued, workers)
+
+	// Redraw at most a few times a second, however many events arrive
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	stopped := func(dl downloader.Download) bool {
+		return dl.Finished() || dl.Status == downloader.StatusPaused
+	}
+
+	interrupted := false
+	remaining := len(urls)
+	for remaining > 0 {
+		select {
+		case sig := <-sigs:
+			interrupted = true
+			for _, id := range ids {
+				if stopped(jobs[id]) {
+					continue
+				}
+				if sig == os.Interrupt {
+					dlr.Pause(id)
+				} else {
+					dlr.Cancel(id)
+				}
+			}
+		case ev := <-events:
+			dl := ev.Download
+			old, job := jobs[dl.ID]
+			if !job {
+				if _, ok := jobs[dl.ParentID]; ok {
+					view.event(ev, false)
+				}
+				continue
+			}
+			jobs[dl.ID] = dl
+			view.event(ev, true)
+			if stopped(dl) && !stopped(old) {
+				remaining--
+			} else if !stopped(dl) && stopped(old) {
+				remaining++ // Resumed
+			}
+		case <-ticker.C:
+			view.tick()
+		}
+	}
+
+	final := make([]downloader.Download, len(ids))
+	failed := false
+	for i, id := range ids {
+		final[i] = jobs[id]
+		failed = failed || outcome(final[i]) == downloader.StatusFailed
+	}
+	view.done(final, time.Since(start), interrupted)
+
+	switch {
+	case failed:
+		return exitFailure
+	case interrupted:
+		return exitInterrupted
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// runFormats implements `cli formats <url>`: print the available format table
+func runFormats(args []string) {
+	fs := flag.NewFlagSet("formats", flag.ExitOnError)
+	jsonFlag := fs.Bool("json", false, "Print formats as JSON")
+	network := networkFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli formats [flags] <url>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	url := fs.Arg(0)
+
+	ctx := context.Background()
+	dlr := newCLIDownloader(ctx, 1)
+
+	var opts downloader.DownloadOptions
+	network(&opts)
+	formats, err := dlr.ListFormats(ctx, url, opts)
+	if err != nil {
+		log.Fatalf("Failed to list formats: %v", err)
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(formats)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEXT\tRESOLUTION\tFPS\tVCODEC\tACODEC\tSIZE\tNOTE")
+	for _, f := range formats {
+		size := "-"
+		if f.Size() > 0 {
+			size = fmt.Sprintf("%.1f MiB", float64(f.Size())/(1024*1024))
+		}
+		fps := "-"
+		if f.FPS > 0 {
+			fps = fmt.Sprintf("%.0f", f.FPS)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.ID, f.Ext, f.Resolution, fps, f.VCodec, f.ACodec, size, f.Note)
+	}
+	w.Flush()
+
+	if heights := downloader.Heights(formats); len(heights) > 0 {
+		fmt.Printf("\nQuality presets:")
+		for _, h := range heights {
+			fmt.Printf(" %dp", h)
+		}
+		fmt.Println(" best audio")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+// runGet implements `cli get [flags] <url>...`: download videos, playlists or
+// channels and wait for them. URLs come from the arguments, a batch file
+// (-a) or stdin. Ctrl+C pauses (keeping .part files so running the same
+// command again resumes), SIGTERM cancels.
+func runGet(args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	options := optionFlags(fs)
+	urlFlag := fs.String("url", "", "URL to download (instead of the argument)")
+	batchFile := fs.String("a", "", "File with one URL per line, - for stdin (blank lines and # comments are skipped)")
+	jobs := fs.Int("jobs", 3, "Concurrent downloads, of URLs or playlist entries")
+	db := fs.String("db", "history.db", "History database finished downloads are recorded in (shared with the desktop app when run from its directory; skipped with a warning while the app has it open), \"\" for none")
+	archivePath := archiveFlag(fs)
+	proxies := fs.String("proxies", "", "Comma-separated proxies for -escalate to try last")
+	jsonOut := fs.Bool("json", false, "Print newline-delimited JSON events and a final result object instead of text")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli get [flags] <url>...\n       cli get [flags] -a <file>\n       ... | cli get [flags]\n")
+		fs.PrintDefaults()
+	}
+	urls := parseInterspersed(fs, args)
+	if *urlFlag != "" {
+		urls = append([]string{*urlFlag}, urls...)
+	}
+
+	// Messages go to stderr when stdout carries JSON
+	var out *jsonOutput
+	msgs := io.Writer(os.Stdout)
+	if *jsonOut {
+		out = newJSONOutput(os.Stdout)
+		msgs = os.Stderr
+	}
+
+	// Batch file, or piped URLs when none were given
+	source := *batchFile
+	if source == "" && len(urls) == 0 && !isTerminal(os.Stdin) {
+		source = "-"
+	}
+	if source != "" {
+		batch, err := readBatchFile(source)
+		if err != nil {
+			out.fatalf("Failed to read URLs: %v", err)
+		}
+		urls = append(urls, batch...)
+	}
+
+	if len(urls) == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	opts := options()
+
+	// Create output dir
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		out.fatalf("Failed to create output directory: %v", err)
+	}
+
+	fmt.Fprintf(msgs, "Initializing VidFetch Core...\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	// A single playlist or channel URL runs its entries on the workers too
+	workers := max(*jobs, 1)
+	dlr := newCLIDownloader(ctx, workers)
+	if *proxies != "" {
+		dlr.SetProxies(strings.Split(*proxies, ","))
+	}
+	var hist *storage.DB
+	if *db != "" {
+		var err error
+		if hist, err = storage.OpenDB(*db); err != nil {
+			// Usually locked by the desktop app; downloading matters more
+			log.Printf("Warning: not recording to the history: %v", err)
+		}
+	}
+	if hist != nil {
+		if err := useLibrary(dlr, hist, *archivePath); err != nil {
+			out.fatalf("Failed to open download archive: %v", err)
+		}
+	} else if *archivePath != "" {
+		archive, err := downloader.NewArchive(*archivePath)
+		if err != nil {
+			out.fatalf("Failed to open download archive: %v", err)
+		}
+		dlr.Archive = archive
+	}
+	if opts.AutoUpdateYtdlp {
+		mode := "stable"
+		if opts.UseNightly {
+			mode = "nightly"
+		}
+		if result, err := dlr.Updater.CheckAndUpdate(ctx, mode); err != nil {
+			log.Printf("Updating yt-dlp failed: %v", err)
+		} else {
+			fmt.Fprintf(msgs, "yt-dlp: %s\n", result)
+		}
+	}
+
+	fmt.Fprintf(msgs, "Output directory: %s\n", opts.OutputDir)
+	var code int
+	switch {
+	case out != nil:
+		out.dlr = dlr
+		code = getMany(ctx, dlr, urls, opts, min(workers, len(urls)), out)
+	case len(urls) > 1:
+		code = getMany(ctx, dlr, urls, opts, min(workers, len(urls)), &batchDisplay{dlr: dlr, tty: isTerminal(os.Stdout)})
+	default:
+		code = getOne(ctx, dlr, urls[0], opts, *archivePath)
+	}
+
+	// Let the last downloads reach the history before closing it
+	cancel()
+	dlr.Wait()
+	if hist != nil {
+		hist.Close()
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// getOne downloads a single URL with a one-line progress display and returns
+// the exit code
+func getOne(ctx context.Context, dlr *downloader.Downloader, url string, opts downloader.DownloadOptions, archivePath string) int {
+	fmt.Printf("Starting download for: %s\n", url)
+
+	start := time.Now()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	// React to progress events instead of polling
+	events, unsubscribe := dlr.Subscribe()
+	defer unsubscribe()
+
+	// Playlists expand into entry jobs, which need the worker running
+	dlr.Start(ctx)
+	id := dlr.QueueDownload(url, opts)
+
+	var err error
+	for {
+		select {
+		case sig := <-sigs:
+			if sig == os.Interrupt {
+				err = dlr.Pause(id)
+			} else {
+				err = dlr.Cancel(id)
+			}
+			if err != nil {
+				log.Printf("\n%v", err)
+			}
+		case ev := <-events:
+			if ev.Download.ID != id && ev.Download.ParentID != id {
+				continue
+			}
+			if ev.Download.ID == id {
+				switch ev.Type {
+				case downloader.EventPaused:
+					fmt.Printf("\nDownload paused, partial files kept. Run the same command again to resume.\n")
+					return exitInterrupted
+				case downloader.EventCancelled:
+					fmt.Printf("\nDownload cancelled\n")
+					return exitInterrupted
+				case downloader.EventFailed:
+					log.Printf("Download failed: %s", ev.Download.Error)
+					return exitFailure
+				case downloader.EventSkipped:
+					fmt.Printf("\nAlready downloaded (in %s), skipped\n", archivePath)
+					return 0
+				case downloader.EventCompleted:
+					return report(dlr, ev.Download, time.Since(start))
+				}
+			}
+
+			p := dlr.GetProgress(id)
+			frags := ""
+			if p.Fragments > 0 {
+				frags = fmt.Sprintf(" frag %d/%d", p.Fragment, p.Fragments)
+			}
+			label := p.Label()
+			if ev.Download.ParentID == id {
+				// Show the entry being downloaded next to the overall progress
+				entry := dlr.GetProgress(ev.Download.ID)
+				label = fmt.Sprintf("%s | entry %d: %s (%.0f%%)", label, ev.Download.PlaylistIndex, entry.Label(), entry.Progress*100)
+			}
+			fmt.Printf("\rProgress: %.1f%% | %s (%.0f%%%s) | %s | ETA: %s | Status: %s   ", p.Progress*100, label, p.PhaseProgress*100, frags, p.Speed, p.ETA, p.Status)
+		}
+	}
+}
+
+// report prints the files of a completed download, or of each entry of a
+// completed playlist, and returns a non-zero exit code when entries failed
+func report(dlr *downloader.Downloader, dl downloader.Download, elapsed time.Duration) int {
+	fmt.Printf("\nDownload completed successfully in %v\n", elapsed)
+
+	downloads := []downloader.Download{dl}
+	if dl.IsCollection() {
+		downloads = dlr.Children(dl.ID)
+	}
+	for _, d := range downloads {
+		switch d.Status {
+		case downloader.StatusFailed:
+			fmt.Printf("Failed: %d %s: %s\n", d.PlaylistIndex, d.URL, d.Error)
+			continue
+		case downloader.StatusSkipped:
+			fmt.Printf("Already downloaded: %s\n", d.Title)
+			continue
+		}
+		for _, path := range d.MediaFiles {
+			fmt.Printf("Saved: %s\n", path)
+		}
+		for _, path := range d.SubtitleFiles {
+			fmt.Printf("Subtitles: %s\n", path)
+		}
+	}
+
+	if dl.ItemsFailed > 0 {
+		fmt.Printf("%s\n", dl.Error)
+		return exitFailure
+	}
+	return 0
+}
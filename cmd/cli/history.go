@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+const historyUsage = `Usage: cli history <command> [flags]
+
+Commands:
+  list [flags]              Search, filter and sort finished downloads
+  delete [flags] <id>...    Remove entries, optionally with their files
+  redownload [flags] <id>   Download an entry again with its original options
+  prune [flags]             Apply (and with flags, change) the retention policy
+  export [flags] <file>     Write entries to a .jsonl or .csv file
+  import [flags] <file>     Merge entries from an exported .jsonl or .csv file
+`
+
+// runHistory implements `cli history`: browse and manage the download history
+// shared with the desktop app
+func runHistory(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, historyUsage)
+		os.Exit(exitUsage)
+	}
+	if isHelp(args[0]) {
+		fmt.Print(historyUsage)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		runHistoryList(args[1:])
+	case "delete":
+		runHistoryDelete(args[1:])
+	case "redownload":
+		runHistoryRedownload(args[1:])
+	case "prune":
+		runHistoryPrune(args[1:])
+	case "export":
+		runHistoryExport(args[1:])
+	case "import":
+		runHistoryImport(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, historyUsage)
+		os.Exit(exitUsage)
+	}
+}
+
+// historyFlagSet returns a flag set with the -db flag shared by all history commands
+func historyFlagSet(name string, usage string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("history "+name, flag.ExitOnError)
+	db := fs.String("db", "history.db", "History database (shared with the desktop app when run from its directory, which must be closed)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli history %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, db
+}
+
+func openHistory(path string) *storage.DB {
+	hist, err := storage.OpenDB(path)
+	if err != nil {
+		log.Fatalf("Failed to open history: %v", err)
+	}
+	return hist
+}
+
+// archiveFlag adds the -archive flag of the commands that download
+func archiveFlag(fs *flag.FlagSet) *string {
+	return fs.String("archive", "archive.txt", "Download archive (shared with the desktop app when run from its directory), \"\" for none")
+}
+
+// useLibrary makes dlr work against the app's history database and download
+// archive: videos already downloaded are recognised, and finished downloads
+// are recorded in both. An empty archivePath leaves the archive out; the
+// error is from opening the archive.
+func useLibrary(dlr *downloader.Downloader, hist *storage.DB, archivePath string) error {
+	dlr.FindDownloaded = hist.Find
+	dlr.OnComplete = func(dl *downloader.Download) {
+		if err := hist.Add(*dl); err != nil {
+			log.Printf("Failed to save history: %v", err)
+		}
+	}
+	if archivePath == "" {
+		return nil
+	}
+
+	// Seeded with the videos downloaded before it existed, as in the app
+	archive, err := downloader.NewArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	if archive.Len() == 0 {
+		for _, key := range hist.ArchiveKeys() {
+			archive.Add(key)
+		}
+	}
+	dlr.Archive = archive
+	return nil
+}
+
+// queryFlags adds the history filter flags to fs; the returned function
+// builds the query once fs is parsed
+func queryFlags(fs *flag.FlagSet) func() storage.Query {
+	search := fs.String("search", "", "Text the title or URL contains")
+	status := fs.String("status", "", "Only this status (completed, failed, cancelled)")
+	platform := fs.String("platform", "", "Only this platform (e.g. youtube)")
+	from := fs.String("from", "", "Finished on or after this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "Finished on or before this date (YYYY-MM-DD)")
+	sortBy := fs.String("sort", storage.SortNewest, "Order: newest, oldest, title, size")
+
+	return func() storage.Query {
+		q := storage.Query{
+			Status:   *status,
+			Platform: *platform,
+			Contains: *search,
+			Sort:     *sortBy,
+		}
+		var err error
+		if q.From, err = parseDate(*from); err != nil {
+			log.Fatalf("Invalid -from: %v", err)
+		}
+		if q.To, err = parseDate(*to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+		if !q.To.IsZero() {
+			q.To = q.To.AddDate(0, 0, 1) // Include the whole day
+		}
+		return q
+	}
+}
+
+func runHistoryList(args []string) {
+	fs, db := historyFlagSet("list", "list [flags]")
+	query := queryFlags(fs)
+	limit := fs.Int("limit", 20, "Entries per page, 0 for all")
+	page := fs.Int("page", 1, "Page to show")
+	asJSON := fs.Bool("json", false, "Print the page as JSON")
+	fs.Parse(args)
+
+	q := query()
+	q.Offset = max(*page-1, 0) * *limit
+	q.Limit = *limit
+
+	hist := openHistory(*db)
+	defer hist.Close()
+	result, err := hist.Query(q)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+		return
+	}
+	if result.Total == 0 {
+		fmt.Println("No downloads found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFINISHED\tSTATUS\tPLATFORM\tSIZE\tTITLE")
+	for _, dl := range result.Downloads {
+		finished := dl.CompletedAt
+		if finished.IsZero() {
+			finished = dl.CreatedAt
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", dl.ID, finished.Format("2006-01-02 15:04"), dl.Status, dl.Platform, downloader.FormatBytes(dl.FileSize), dl.Title)
+	}
+	w.Flush()
+	fmt.Printf("Showing %d-%d of %d\n", q.Offset+1, q.Offset+len(result.Downloads), result.Total)
+}
+
+func runHistoryDelete(args []string) {
+	fs, db := historyFlagSet("delete", "delete [flags] <id>...")
+	files := fs.Bool("files", false, "Also delete the downloaded files from disk")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	hist := openHistory(*db)
+	defer hist.Close()
+	failed := false
+	for _, id := range fs.Args() {
+		dl, err := hist.Delete(id)
+		if err != nil {
+			log.Print(err)
+			failed = true
+			continue
+		}
+		if *files {
+			if err := storage.RemoveFiles(dl); err != nil {
+				log.Printf("Failed to delete files of %s: %v", id, err)
+				failed = true
+			}
+		}
+		fmt.Printf("Deleted %s (%s)\n", id, dl.Title)
+	}
+	if failed {
+		os.Exit(exitFailure)
+	}
+}
+
+// runHistoryRedownload queues a history entry again and waits for it,
+// recording the new download in the history
+func runHistoryRedownload(args []string) {
+	fs, db := historyFlagSet("redownload", "redownload [flags] <id>")
+	archivePath := archiveFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	hist := openHistory(*db)
+	defer hist.Close()
+	entry := hist.Lookup(fs.Arg(0))
+	if entry == nil {
+		log.Fatalf("history entry not found: %s", fs.Arg(0))
+	}
+
+	if failed := redownload(hist, *archivePath, []downloader.Download{*entry}, 1); failed > 0 {
+		hist.Close()
+		os.Exit(exitFailure)
+	}
+}
+
+// redownload downloads history entries again with their original options,
+// waits for them and records the new downloads in hist and the archive.
+// Returns how many failed.
+func redownload(hist *storage.DB, archivePath string, entries []downloader.Download, workers int) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	dlr := newCLIDownloader(ctx, workers)
+	if err := useLibrary(dlr, hist, archivePath); err != nil {
+		log.Fatalf("Failed to open download archive: %v", err)
+	}
+	defer dlr.Wait() // For the last history records
+	defer cancel()
+	events, unsubscribe := dlr.Subscribe()
+	defer unsubscribe()
+	dlr.Start(ctx)
+
+	pending := make(map[string]bool)
+	for _, entry := range entries {
+		opts := entry.Options
+		opts.Duplicates = downloader.DuplicateRedownload // It is archived by now
+		fmt.Printf("Downloading again: %s\n", entry.URL)
+		pending[dlr.QueueDownload(entry.URL, opts)] = true
+	}
+
+	failed := 0
+	for len(pending) > 0 {
+		ev := <-events
+		if !pending[ev.Download.ID] || !ev.Download.Finished() {
+			continue
+		}
+		delete(pending, ev.Download.ID)
+		if ev.Download.Status != downloader.StatusCompleted {
+			failed++
+			fmt.Printf("Failed: %s (%s): %s\n", ev.Download.Title, ev.Download.URL, ev.Download.Error)
+			continue
+		}
+		fmt.Printf("Downloaded: %s\n", ev.Download.Title)
+		for _, path := range ev.Download.MediaFiles {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	return failed
+}
+
+// runHistoryPrune drops the entries the retention policy no longer keeps.
+// Flags change the saved policy first; the rest of it stays as it was.
+func runHistoryPrune(args []string) {
+	fs, db := historyFlagSet("prune", "prune [flags]")
+	maxEntries := fs.Int("max-entries", 0, "Keep at most this many entries, 0 for no limit")
+	maxAge := fs.Int("max-age", 0, "Drop entries older than this many days, 0 for no limit")
+	dropFailures := fs.Bool("drop-failures", false, "Drop failed and cancelled downloads")
+	collapse := fs.Bool("collapse-retries", false, "Keep only the latest attempt at each video")
+	archive := fs.String("archive", "", "Append pruned entries to this gzipped JSON lines file (\"\" to stop archiving)")
+	fs.Parse(args)
+
+	hist := openHistory(*db)
+	defer hist.Close()
+
+	policy := hist.Retention()
+	changed := false
+	fs.Visit(func(f *flag.Flag) {
+		changed = true
+		switch f.Name {
+		case "max-entries":
+			policy.MaxEntries = *maxEntries
+		case "max-age":
+			policy.MaxAgeDays = *maxAge
+		case "drop-failures":
+			policy.DropFailures = *dropFailures
+		case "collapse-retries":
+			policy.CollapseRetries = *collapse
+		case "archive":
+			policy.ArchivePath = *archive
+		}
+	})
+
+	if changed {
+		if err := hist.SetRetention(policy); err != nil {
+			hist.Close()
+			log.Fatalf("Failed to save retention policy: %v", err)
+		}
+	}
+	pruned, err := hist.Prune()
+	if err != nil {
+		hist.Close()
+		log.Fatalf("Failed to prune history: %v", err)
+	}
+
+	fmt.Printf("Retention: max entries %d, max age %d days, drop failures %t, collapse retries %t, archive %q\n",
+		policy.MaxEntries, policy.MaxAgeDays, policy.DropFailures, policy.CollapseRetries, policy.ArchivePath)
+	fmt.Printf("Pruned %d entries\n", pruned)
+}
+
+// runHistoryExport writes the entries matching the filter flags to a file
+// (or stdout with "-"), as JSON Lines or CSV
+func runHistoryExport(args []string) {
+	fs, db := historyFlagSet("export", "export [flags] <file.jsonl|file.csv|->")
+	query := queryFlags(fs)
+	format := fs.String("format", "", "jsonl or csv (default: from the file extension)")
+	cols := fs.String("columns", "", "Comma-separated CSV columns (default: "+strings.Join(storage.DefaultColumns, ",")+")")
+	listColumns := fs.Bool("list-columns", false, "Print every available CSV column and exit")
+	fs.Parse(args)
+
+	if *listColumns {
+		fmt.Println(strings.Join(storage.Columns(), "\n"))
+		return
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = storage.FormatForPath(path)
+	}
+	var columns []string
+	if *cols != "" {
+		columns = strings.Split(*cols, ",")
+	}
+
+	hist := openHistory(*db)
+	defer hist.Close()
+	result, err := hist.Query(query())
+	if err != nil {
+		hist.Close()
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if path != "-" {
+		if out, err = os.Create(path); err != nil {
+			hist.Close()
+			log.Fatal(err)
+		}
+	}
+	err = storage.Export(out, result.Downloads, *format, columns)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if path != "-" {
+			os.Remove(path)
+		}
+		hist.Close()
+		log.Fatalf("Export failed: %v", err)
+	}
+	if path != "-" {
+		fmt.Printf("Exported %d entries to %s\n", len(result.Downloads), path)
+	}
+}
+
+// runHistoryImport merges an export, e.g. from another machine, into the
+// history
+func runHistoryImport(args []string) {
+	fs, db := historyFlagSet("import", "import [flags] <file.jsonl|file.csv>")
+	format := fs.String("format", "", "jsonl or csv (default: from the file extension)")
+	by := fs.String("by", storage.MergeByID, "Skip entries already in the history by: id, or video (also skips videos downloaded here)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = storage.FormatForPath(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	downloads, err := storage.ReadExport(f, *format)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	hist := openHistory(*db)
+	defer hist.Close()
+	added, err := storage.Merge(hist, downloads, *by)
+	if err != nil {
+		hist.Close()
+		log.Fatalf("Import failed after %d entries: %v", added, err)
+	}
+	fmt.Printf("Imported %d of %d entries\n", added, len(downloads))
+}
+
+// parseDate parses a YYYY-MM-DD date in local time; empty means no date
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
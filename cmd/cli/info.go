@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// runInfo implements `cli info <url>`: print a URL's metadata without downloading it
+func runInfo(args []string) {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	jsonFlag := fs.Bool("json", false, "Print raw metadata as JSON")
+	noPlaylist := fs.Bool("no-playlist", false, "Show only the video when the URL is also a playlist")
+	network := networkFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli info [flags] <url>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	url := fs.Arg(0)
+
+	ctx := context.Background()
+	dlr := newCLIDownloader(ctx, 1)
+
+	opts := downloader.DownloadOptions{NoPlaylist: *noPlaylist}
+	network(&opts)
+	info, err := dlr.FetchInfo(ctx, url, opts)
+	if err != nil {
+		log.Fatalf("Failed to fetch info: %v", err)
+	}
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(info)
+		return
+	}
+
+	fmt.Printf("Title:     %s\n", info.Title)
+	fmt.Printf("Platform:  %s\n", info.Extractor)
+	fmt.Printf("Uploader:  %s\n", info.Uploader)
+	if info.Type == "playlist" {
+		fmt.Printf("Entries:   %d\n", info.PlaylistCount)
+	} else {
+		fmt.Printf("Duration:  %v\n", time.Duration(info.Duration)*time.Second)
+	}
+	if size := info.EstimatedSize(); size > 0 {
+		fmt.Printf("Size:      ~%.1f MiB\n", float64(size)/(1024*1024))
+	}
+	fmt.Printf("Thumbnail: %s\n", info.Thumbnail)
+	fmt.Printf("URL:       %s\n", info.WebpageURL)
+}
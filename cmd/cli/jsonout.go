@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// jsonOutput writes newline-delimited JSON on stdout for `get -json`: every
+// downloader.Event of the batch, then one jsonResult. The command prints its
+// messages to stderr instead and the engine only logs (to stderr), so stdout
+// stays parseable.
+type jsonOutput struct {
+	dlr *downloader.Downloader
+	enc *json.Encoder
+}
+
+// jsonResult is the last line of `get -json`
+type jsonResult struct {
+	Type     string      `json:"type"` // Always "result"
+	OK       bool        `json:"ok"`
+	ExitCode int         `json:"exit_code"`
+	Elapsed  float64     `json:"elapsed_seconds"`
+	Error    string      `json:"error,omitempty"` // Set when the batch could not start
+	Jobs     []jobResult `json:"jobs"`
+}
+
+// jobResult is the outcome of one URL, or of one playlist entry
+type jobResult struct {
+	ID        string      `json:"id"`
+	URL       string      `json:"url"`
+	Title     string      `json:"title"`
+	Status    string      `json:"status"`
+	Outcome   string      `json:"outcome"` // completed, skipped, paused (or cancelled) or failed
+	Files     []string    `json:"files"`   // Media, subtitle and thumbnail files
+	FileSize  int64       `json:"file_size"`
+	Error     string      `json:"error,omitempty"`
+	ErrorKind string      `json:"error_kind,omitempty"` // See downloader.ErrorKind
+	Transient bool        `json:"transient,omitempty"`  // Retrying later may succeed
+	Blocking  bool        `json:"blocking,omitempty"`   // The site refused us, see -escalate
+	Entries   []jobResult `json:"entries,omitempty"`    // Playlist and channel entries
+}
+
+// newJSONOutput writes the JSON lines to w
+func newJSONOutput(w io.Writer) *jsonOutput {
+	return &jsonOutput{enc: json.NewEncoder(w)}
+}
+
+// fatalf ends the command with a failed result, or logs the message and
+// exits when o is nil (text output)
+func (o *jsonOutput) fatalf(format string, args ...any) {
+	if o == nil {
+		log.Fatalf(format, args...)
+	}
+	o.enc.Encode(jsonResult{Type: "result", ExitCode: exitFailure, Error: fmt.Sprintf(format, args...), Jobs: []jobResult{}})
+	os.Exit(exitFailure)
+}
+
+func (o *jsonOutput) queued(jobs []downloader.Download, workers int) {}
+
+func (o *jsonOutput) event(ev downloader.Event, job bool) {
+	o.enc.Encode(ev)
+}
+
+func (o *jsonOutput) tick() {}
+
+func (o *jsonOutput) done(jobs []downloader.Download, elapsed time.Duration, interrupted bool) {
+	result := jsonResult{Type: "result", OK: true, Elapsed: elapsed.Seconds(), Jobs: []jobResult{}}
+	for _, dl := range jobs {
+		r := newJobResult(dl)
+		if dl.IsCollection() {
+			for _, entry := range o.dlr.Children(dl.ID) {
+				r.Entries = append(r.Entries, newJobResult(entry))
+			}
+		}
+		if r.Outcome == downloader.StatusFailed {
+			result.OK = false
+			result.ExitCode = exitFailure
+		}
+		result.Jobs = append(result.Jobs, r)
+	}
+	if interrupted && result.OK {
+		result.OK = false
+		result.ExitCode = exitInterrupted
+	}
+	o.enc.Encode(result)
+}
+
+func newJobResult(dl downloader.Download) jobResult {
+	r := jobResult{
+		ID:        dl.ID,
+		URL:       dl.URL,
+		Title:     dl.Title,
+		Status:    dl.Status,
+		Outcome:   outcome(dl),
+		Files:     dl.Files(),
+		FileSize:  dl.FileSize,
+		Error:     dl.Error,
+		ErrorKind: dl.ErrorKind,
+	}
+	if r.Files == nil {
+		r.Files = []string{}
+	}
+	kind := downloader.ErrorKind(dl.ErrorKind)
+	r.Transient = kind.Transient()
+	r.Blocking = kind.Blocking()
+	return r
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// Exit codes shared by every command
+const (
+	exitFailure     = 1   // A download or operation failed
+	exitUsage       = 2   // Bad command line, as for flag parse errors
+	exitInterrupted = 130 // Paused or cancelled by a signal
+)
+
+// isHelp reports whether arg asks for usage help
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// parseInterspersed parses fs allowing flags after the arguments, as in
+// `cli get <url> -audio`, and returns the arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// networkFlags adds the flags that affect how yt-dlp talks to sites; the
+// returned function fills them into opts once fs is parsed
+func networkFlags(fs *flag.FlagSet) func(opts *downloader.DownloadOptions) {
+	cookies := fs.Bool("cookies", false, "Use browser cookies (see -browser)")
+	browser := fs.String("browser", "chrome", "Browser to read cookies from: chrome, firefox, safari, edge, brave...")
+	proxy := fs.String("proxy", "", "Proxy URL")
+	userAgent := fs.String("ua", "", "Custom User Agent")
+	impersonate := fs.String("impersonate", "", "Impersonate a browser's TLS fingerprint (e.g. chrome)")
+
+	return func(opts *downloader.DownloadOptions) {
+		opts.UseCookies = *cookies
+		opts.BrowserName = *browser
+		opts.ProxyURL = *proxy
+		opts.UserAgent = *userAgent
+		opts.Impersonate = *impersonate
+	}
+}
+
+// optionFlags adds a flag for every DownloadOptions setting; the returned
+// function builds the options once fs is parsed
+func optionFlags(fs *flag.FlagSet) func() downloader.DownloadOptions {
+	// Quality
+	format := fs.String("format", "best", "Quality preset (best, 1080p, 720p, audio) or yt-dlp format selector")
+	container := fs.String("container", "", "Container to merge into (mp4, mkv, webm) or audio format with -audio (mp3, m4a, opus...)")
+	audio := fs.Bool("audio", false, "Download audio only")
+
+	// Subtitles
+	subs := fs.Bool("subs", true, "Download subtitles")
+	autoSubs := fs.Bool("auto-subs", true, "With -subs, also download auto-generated subtitles")
+	subLangs := fs.String("sub-langs", "all", "Comma-separated subtitle languages (e.g. en,de) or all")
+	subFormat := fs.String("sub-format", "srt", "Convert subtitles to this format (srt, vtt, ass), empty to keep")
+	embed := fs.Bool("embed", true, "Embed subtitles in the video")
+
+	// Output
+	outputDir := fs.String("out", "./downloads", "Output directory")
+	template := fs.String("template", "%(title)s.%(ext)s", "yt-dlp output file name template")
+	thumbnail := fs.Bool("thumbnail", false, "Also save the thumbnail image")
+
+	// Playlists
+	noPlaylist := fs.Bool("no-playlist", false, "Download only the video when the URL is also a playlist")
+	items := fs.String("items", "", "Playlist entries to download (e.g. 1-3,7,10-)")
+	playlistStart := fs.Int("playlist-start", 0, "First playlist entry to download")
+	playlistEnd := fs.Int("playlist-end", 0, "Last playlist entry to download")
+	duplicates := fs.String("duplicates", downloader.DuplicateSkip, "With -archive, what to do with videos already downloaded: skip, redownload, link")
+
+	// Anti-blocking
+	network := networkFlags(fs)
+	rateLimit := fs.String("limit", "", "Rate limit (e.g. 2M)")
+	escalate := fs.Bool("escalate", false, "On blocking errors retry with impersonation, cookies, nightly engine, then -proxies")
+
+	// Engine
+	update := fs.Bool("update", false, "Update yt-dlp before downloading")
+	nightly := fs.Bool("nightly", false, "Use the nightly yt-dlp build")
+
+	return func() downloader.DownloadOptions {
+		absPath, _ := filepath.Abs(*outputDir)
+		var langs []string
+		for _, lang := range strings.Split(*subLangs, ",") {
+			if lang = strings.TrimSpace(lang); lang != "" {
+				langs = append(langs, lang)
+			}
+		}
+
+		opts := downloader.DownloadOptions{
+			Format:           *format,
+			VideoFormat:      *container,
+			AudioOnly:        *audio,
+			DownloadSubs:     *subs,
+			DownloadAutoSubs: *subs && *autoSubs,
+			SubtitleLangs:    langs,
+			SubtitleFormat:   *subFormat,
+			EmbedSubtitles:   *embed,
+			OutputDir:        absPath,
+			OutputTemplate:   *template,
+			WriteThumbnail:   *thumbnail,
+			NoPlaylist:       *noPlaylist,
+			PlaylistItems:    *items,
+			PlaylistStart:    *playlistStart,
+			PlaylistEnd:      *playlistEnd,
+			Duplicates:       *duplicates,
+			RateLimit:        *rateLimit,
+			AutoEscalate:     *escalate,
+			AutoUpdateYtdlp:  *update,
+			UseNightly:       *nightly,
+		}
+		network(&opts)
+		return opts
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+// runStats implements `cli stats`: download totals, success rates and
+// throughput per day, week and platform, from the history
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	db := fs.String("db", "history.db", "History database (shared with the desktop app when run from its directory, which must be closed)")
+	from := fs.String("from", "", "Only downloads finished on or after this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only downloads finished on or before this date (YYYY-MM-DD)")
+	platform := fs.String("platform", "", "Only this platform (e.g. youtube)")
+	days := fs.Int("days", 14, "Days to list, newest first")
+	weeks := fs.Int("weeks", 8, "Weeks to list, newest first")
+	top := fs.Int("top", 10, "Largest files to list")
+	asJSON := fs.Bool("json", false, "Print all statistics as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli stats [flags]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *days < 0 || *weeks < 0 || *top < 0 {
+		fmt.Fprintf(fs.Output(), "-days, -weeks and -top must not be negative\n")
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	q := storage.Query{Platform: *platform}
+	var err error
+	if q.From, err = parseDate(*from); err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	if q.To, err = parseDate(*to); err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+	if !q.To.IsZero() {
+		q.To = q.To.AddDate(0, 0, 1) // Include the whole day
+	}
+
+	hist := openHistory(*db)
+	page, err := hist.Query(q)
+	hist.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stats := storage.ComputeStats(page.Downloads, *top)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(stats)
+		return
+	}
+	if stats.Total.Downloads == 0 {
+		fmt.Println("No downloads found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	printBuckets(w, "TOTAL", []storage.Bucket{stats.Total})
+	printBuckets(w, "DAY", stats.Days[:min(*days, len(stats.Days))])
+	printBuckets(w, "WEEK", stats.Weeks[:min(*weeks, len(stats.Weeks))])
+	printBuckets(w, "PLATFORM", stats.Platforms)
+	w.Flush()
+
+	if len(stats.Largest) > 0 {
+		fmt.Println("\nLargest downloads:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, dl := range stats.Largest {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", downloader.FormatBytes(dl.FileSize), dl.Platform, dl.Title)
+		}
+		w.Flush()
+	}
+
+	if len(stats.Errors) > 0 {
+		fmt.Println("\nMost common errors:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, e := range stats.Errors {
+			fmt.Fprintf(w, "  %d\t%s\t%s\n", e.Count, e.Kind, e.Example)
+		}
+		w.Flush()
+	}
+}
+
+// printBuckets writes one table section; sections share w so their columns line up
+func printBuckets(w *tabwriter.Writer, title string, buckets []storage.Bucket) {
+	fmt.Fprintf(w, "%s\tDOWNLOADS\tOK\tFAILED\tCANCELLED\tSUCCESS\tSIZE\tAVG SIZE\tSPEED\t\n", title)
+	for _, b := range buckets {
+		speed := "-"
+		if b.Throughput > 0 {
+			speed = downloader.FormatBytes(int64(b.Throughput)) + "/s"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.0f%%\t%s\t%s\t%s\t\n", b.Key, b.Downloads, b.Completed, b.Failed, b.Cancelled, b.SuccessRate*100,
+			downloader.FormatBytes(b.Bytes), downloader.FormatBytes(b.AvgSize), speed)
+	}
+	fmt.Fprintf(w, "\t\t\t\t\t\t\t\t\t\n")
+}
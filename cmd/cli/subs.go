@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+const subsUsage = `Usage: cli subs <command> [flags]
+
+Commands:
+  add [flags] <url>   Subscribe to a channel or playlist
+  list                List subscriptions
+  remove <id>         Remove a subscription
+  sync [flags] [id]   Download new entries of one or all subscriptions
+`
+
+// runSubs implements `cli subs`: manage channel and playlist subscriptions
+func runSubs(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, subsUsage)
+		os.Exit(exitUsage)
+	}
+	if isHelp(args[0]) {
+		fmt.Print(subsUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		runSubsAdd(args[1:])
+	case "list":
+		runSubsList(args[1:])
+	case "remove":
+		runSubsRemove(args[1:])
+	case "sync":
+		runSubsSync(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, subsUsage)
+		os.Exit(exitUsage)
+	}
+}
+
+// subsFlagSet returns a flag set with the -file flag shared by all subs commands
+func subsFlagSet(name string, usage string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("subs "+name, flag.ExitOnError)
+	file := fs.String("file", "subscriptions.json", "Subscriptions file (shared with the desktop app when run from its directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli subs %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, file
+}
+
+func loadSubscriptions(path string) *storage.Subscriptions {
+	subs, err := storage.NewSubscriptions(path)
+	if err != nil {
+		log.Fatalf("Failed to load subscriptions: %v", err)
+	}
+	return subs
+}
+
+func newCLIDownloader(ctx context.Context, workers int) *downloader.Downloader {
+	binPath, err := downloader.InstallYtDlp(ctx)
+	if err != nil {
+		log.Printf("Installing yt-dlp failed (might already be installed or network issue): %v", err)
+	}
+	dlr := downloader.NewDownloader(workers)
+	dlr.BinPath = binPath
+	dlr.Updater = downloader.NewUpdater(binPath)
+	return dlr
+}
+
+func runSubsAdd(args []string) {
+	fs, file := subsFlagSet("add", "add [flags] <url>")
+	interval := fs.Int("interval", storage.DefaultSyncInterval, "Minutes between scheduled syncs")
+	onlyNew := fs.Bool("only-new", false, "Skip the entries published so far")
+	options := optionFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	opts := options()
+
+	subs := loadSubscriptions(*file)
+	sub, err := subs.Add(fs.Arg(0), opts, *interval)
+	if err != nil {
+		log.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if *onlyNew {
+		ctx := context.Background()
+		if err := subs.MarkExisting(ctx, newCLIDownloader(ctx, 1), sub.ID); err != nil {
+			subs.Remove(sub.ID)
+			log.Fatalf("Failed to list entries: %v", err)
+		}
+	}
+	fmt.Printf("Subscribed: %s (%s)\n", sub.URL, sub.ID)
+	if !*onlyNew {
+		fmt.Printf("Run `cli subs sync %s` to download its entries\n", sub.ID)
+	}
+}
+
+func runSubsList(args []string) {
+	fs, file := subsFlagSet("list", "list [flags]")
+	fs.Parse(args)
+
+	list := loadSubscriptions(*file).Get()
+	if len(list) == 0 {
+		fmt.Println("No subscriptions")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tURL\tEVERY\tDOWNLOADED\tLAST CHECKED\tERROR")
+	for _, sub := range list {
+		checked := "never"
+		if !sub.LastChecked.IsZero() {
+			checked = sub.LastChecked.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%dm\t%d\t%s\t%s\n", sub.ID, sub.Title, sub.URL, sub.IntervalMinutes, len(sub.Seen), checked, sub.LastError)
+	}
+	w.Flush()
+}
+
+func runSubsRemove(args []string) {
+	fs, file := subsFlagSet("remove", "remove [flags] <id>")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	if err := loadSubscriptions(*file).Remove(fs.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Removed %s\n", fs.Arg(0))
+}
+
+// runSubsSync syncs one or all subscriptions and waits for the queued
+// entries to finish, so it can run from cron
+func runSubsSync(args []string) {
+	fs, file := subsFlagSet("sync", "sync [flags] [id]")
+	due := fs.Bool("due", false, "Only sync subscriptions whose interval has elapsed")
+	workers := fs.Int("jobs", 2, "Concurrent downloads")
+	db := fs.String("db", "history.db", "History database that records the downloads and knows earlier ones (shared with the desktop app, which must be closed)")
+	archivePath := archiveFlag(fs)
+	fs.Parse(args)
+
+	subs := loadSubscriptions(*file)
+	var targets []storage.Subscription
+	switch {
+	case fs.NArg() == 1:
+		for _, sub := range subs.Get() {
+			if sub.ID == fs.Arg(0) {
+				targets = append(targets, sub)
+			}
+		}
+		if len(targets) == 0 {
+			log.Fatalf("subscription not found: %s", fs.Arg(0))
+		}
+	case *due:
+		targets = subs.Due(time.Now())
+	default:
+		targets = subs.Get()
+	}
+
+	hist := openHistory(*db)
+	defer hist.Close()
+
+	// Entries downloaded before, by the app or another command, are skipped
+	ctx, cancel := context.WithCancel(context.Background())
+	dlr := newCLIDownloader(ctx, max(*workers, 1))
+	if err := useLibrary(dlr, hist, *archivePath); err != nil {
+		log.Fatalf("Failed to open download archive: %v", err)
+	}
+	events, unsubscribe := dlr.Subscribe()
+	defer unsubscribe()
+	dlr.Start(ctx)
+
+	pending := make(map[string]bool)
+	failedSyncs := 0
+	for _, sub := range targets {
+		ids, err := subs.Sync(ctx, dlr, sub.ID)
+		if err != nil {
+			log.Printf("Sync failed for %s: %v", sub.URL, err)
+			failedSyncs++
+			continue
+		}
+		fmt.Printf("%s: %d new entries\n", sub.URL, len(ids))
+		for _, id := range ids {
+			pending[id] = true
+		}
+	}
+
+	failed := 0
+	for len(pending) > 0 {
+		ev := <-events
+		if !pending[ev.Download.ID] || !ev.Download.Finished() {
+			continue
+		}
+		delete(pending, ev.Download.ID)
+		if err := subs.Finished(ev.Download); err != nil {
+			log.Printf("Failed to save subscriptions: %v", err)
+		}
+		switch ev.Download.Status {
+		case downloader.StatusCompleted:
+			fmt.Printf("Downloaded: %s\n", ev.Download.Title)
+		case downloader.StatusSkipped:
+			fmt.Printf("Already downloaded: %s\n", ev.Download.Title)
+		default:
+			failed++
+			fmt.Printf("Failed: %s (%s): %s\n", ev.Download.Title, ev.Download.URL, ev.Download.Error)
+		}
+	}
+
+	// Let the last downloads reach the history before closing it
+	cancel()
+	dlr.Wait()
+	if failed > 0 || failedSyncs > 0 {
+		hist.Close()
+		os.Exit(exitFailure)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runUpdate implements `cli update`: update yt-dlp to the latest stable or
+// nightly build
+func runUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	nightly := fs.Bool("nightly", false, "Switch to the nightly build")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli update [flags]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	ctx := context.Background()
+	dlr := newCLIDownloader(ctx, 1)
+	mode := "stable"
+	if *nightly {
+		mode = "nightly"
+	}
+	result, err := dlr.Updater.CheckAndUpdate(ctx, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result)
+}
+
+// runVersion implements `cli version`
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli version\n")
+	}
+	fs.Parse(args)
+
+	fmt.Printf("VidFetch %s\n", version)
+	ctx := context.Background()
+	dlr := newCLIDownloader(ctx, 1)
+	ytdlp, err := dlr.Updater.GetVersion(ctx)
+	if err != nil {
+		log.Fatalf("yt-dlp: %v", err)
+	}
+	fmt.Printf("yt-dlp %s\n", ytdlp)
+}
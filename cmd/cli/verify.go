@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+// runVerify implements `cli verify`: check that the files of every completed
+// download still exist unchanged, optionally downloading broken ones again.
+// Exits 1 when broken files remain.
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	db := fs.String("db", "history.db", "History database (shared with the desktop app when run from its directory, which must be closed)")
+	quick := fs.Bool("quick", false, "Compare file sizes only, without hashing contents")
+	requeue := fs.Bool("requeue", false, "Download entries with missing, truncated or changed files again")
+	workers := fs.Int("jobs", 2, "Concurrent downloads with -requeue")
+	archivePath := archiveFlag(fs)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: cli verify [flags]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	hist := openHistory(*db)
+	defer hist.Close()
+
+	report, err := storage.Verify(context.Background(), hist, storage.VerifyOptions{
+		Quick: *quick,
+		OnProgress: func(done int, total int) {
+			if !*asJSON {
+				fmt.Fprintf(os.Stderr, "\rChecked %d/%d", done, total)
+			}
+		},
+	})
+	if err != nil {
+		hist.Close()
+		log.Fatalf("Verify failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		fmt.Fprintln(os.Stderr)
+		for _, result := range report.Results {
+			fmt.Printf("%s  %s\n", result.ID, result.Title)
+			for _, f := range result.Files {
+				switch f.Result {
+				case storage.FileOK:
+				case storage.FileMoved:
+					fmt.Printf("  moved      %s -> %s\n", f.Path, f.MovedTo)
+				case storage.FileMissing:
+					fmt.Printf("  missing    %s\n", f.Path)
+				default:
+					fmt.Printf("  %-10s %s (%s, expected %s)\n", f.Result, f.Path, downloader.FormatBytes(f.Size), downloader.FormatBytes(f.Expected))
+				}
+			}
+		}
+		fmt.Printf("%d checked: %d ok, %d moved, %d broken\n", report.Checked, report.OK, report.Moved, report.Broken)
+	}
+
+	if report.Broken == 0 {
+		return
+	}
+	if !*requeue {
+		if !*asJSON {
+			fmt.Println("Run again with -requeue to download the broken entries again")
+		}
+		hist.Close()
+		os.Exit(exitFailure)
+	}
+
+	var entries []downloader.Download
+	for _, result := range report.Results {
+		if !result.Broken {
+			continue
+		}
+		if err := storage.SetAsideBroken(result); err != nil {
+			log.Printf("Failed to set aside broken files of %s: %v", result.ID, err)
+			continue
+		}
+		if entry := hist.Lookup(result.ID); entry != nil {
+			entries = append(entries, *entry)
+		}
+	}
+	if failed := redownload(hist, *archivePath, entries, max(*workers, 1)); failed > 0 || len(entries) < report.Broken {
+		hist.Close()
+		os.Exit(exitFailure)
+	}
+}
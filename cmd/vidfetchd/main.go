@@ -0,0 +1,170 @@
+// vidfetchd runs VidFetch without the GUI: the download queue, history and
+// archive behind a token-protected HTTP/JSON API on localhost.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/server"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8765", "Address to listen on")
+	dataDir := flag.String("data", ".", "Directory of the history, queue and archive files")
+	outputDir := flag.String("out", "./downloads", "Output directory of downloads that do not set one")
+	workers := flag.Int("jobs", 3, "Concurrent downloads")
+	token := flag.String("token", os.Getenv("VIDFETCH_TOKEN"), "API token (default $VIDFETCH_TOKEN, else read from or generated into <data>/token)")
+	ytdlpPath := flag.String("yt-dlp", "", "yt-dlp binary to use instead of the managed one")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: vidfetchd [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+	data := func(name string) string {
+		return filepath.Join(*dataDir, name)
+	}
+
+	if *token == "" {
+		var err error
+		if *token, err = loadToken(data("token")); err != nil {
+			log.Fatalf("Failed to set up the API token: %v", err)
+		}
+		log.Printf("API token in %s", data("token"))
+	}
+
+	if host, _, err := net.SplitHostPort(*addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			log.Printf("Warning: listening on %s, the API is reachable from other machines", *addr)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hist, err := storage.OpenHistoryDB(data("history.db"), data("history.json"))
+	if err != nil {
+		log.Fatalf("Failed to open history: %v", err)
+	}
+	if pruned, err := hist.Prune(); err != nil {
+		log.Printf("Failed to prune history: %v", err)
+	} else if pruned > 0 {
+		log.Printf("Pruned %d history entries", pruned)
+	}
+
+	queue, err := storage.NewQueue(data("queue.json"))
+	if err != nil {
+		log.Printf("Failed to load queue: %v", err)
+	}
+	escalation, err := storage.NewEscalation(data("escalation.json"))
+	if err != nil {
+		log.Printf("Failed to load escalation settings: %v", err)
+	}
+	archive, err := downloader.NewArchive(data("archive.txt"))
+	if err != nil {
+		log.Fatalf("Failed to load download archive: %v", err)
+	} else if archive.Len() == 0 {
+		for _, key := range hist.ArchiveKeys() {
+			archive.Add(key)
+		}
+	}
+
+	dlr := downloader.NewDownloader(max(*workers, 1))
+	dlr.Archive = archive
+	dlr.FindDownloaded = hist.Find
+	dlr.SetSiteLevels(escalation.GetLevels())
+	dlr.SetProxies(escalation.GetProxies())
+	dlr.OnSiteLevel = func(site string, level int) {
+		if err := escalation.SetLevel(site, level); err != nil {
+			log.Printf("Failed to save escalation level: %v", err)
+		}
+	}
+	dlr.OnChange = func(dl *downloader.Download) {
+		if err := queue.Update(*dl); err != nil {
+			log.Printf("Failed to save queue: %v", err)
+		}
+	}
+	dlr.OnComplete = func(dl *downloader.Download) {
+		if err := hist.Add(*dl); err != nil {
+			log.Printf("Failed to save history: %v", err)
+		}
+	}
+
+	binPath := *ytdlpPath
+	if binPath == "" {
+		if binPath, err = downloader.InstallYtDlp(ctx); err != nil {
+			log.Printf("Failed to install yt-dlp: %v", err)
+		}
+	}
+	dlr.BinPath = binPath
+	dlr.Updater = downloader.NewUpdater(binPath)
+	log.Printf("yt-dlp ready at: %s", binPath)
+
+	dlr.Start(ctx)
+	dlr.Restore(queue.Get())
+
+	api := server.New(ctx, dlr, hist, *token)
+	api.Defaults.OutputDir, _ = filepath.Abs(*outputDir)
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           api,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Listening on http://%s", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Server failed: %v", err)
+	}
+
+	// Let the workers stop and the last downloads reach the history
+	stop()
+	dlr.Wait()
+	if err := hist.Close(); err != nil {
+		log.Printf("Failed to close history: %v", err)
+	}
+	log.Printf("Stopped")
+}
+
+// loadToken reads the token file, creating it with a random token (readable
+// by the owner only) on first run
+func loadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, os.WriteFile(path, []byte(token+"\n"), 0600)
+}
@@ -0,0 +1,286 @@
+package downloader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Duplicate policies for DownloadOptions.Duplicates, applied to videos
+// already in the archive
+const (
+	DuplicateSkip       = "skip"       // Default: don't download the video again
+	DuplicateRedownload = "redownload" // Download it again anyway
+	DuplicateLink       = "link"       // Complete with the files of the earlier download
+)
+
+// errDuplicate is returned by a run whose video is already archived and,
+// per Options.Duplicates, was not downloaded again
+var errDuplicate = errors.New("already downloaded")
+
+// Printed by yt-dlp when --download-archive makes it skip a video
+const archivedMarker = "has already been recorded in the archive"
+
+// Archive records the videos downloaded so far in a yt-dlp --download-archive
+// file: one "<extractor> <video id>" line per video, so the same video is
+// recognised whatever URL it was queued from
+type Archive struct {
+	path string
+	mu   sync.RWMutex
+	keys map[string]bool
+}
+
+// NewArchive opens the archive file at path, creating it on first use
+func NewArchive(path string) (*Archive, error) {
+	a := &Archive{
+		path: path,
+		keys: make(map[string]bool),
+	}
+
+	// Ensure dir exists
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+
+	if err := a.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Path returns the archive file, as passed to yt-dlp
+func (a *Archive) Path() string {
+	return a.path
+}
+
+// Load re-reads the archive file, picking up lines written by yt-dlp
+func (a *Archive) Load() error {
+	keys, err := readArchive(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key := range keys {
+		a.keys[key] = true
+	}
+	return nil
+}
+
+// Has reports whether the video with the given archive key was downloaded
+func (a *Archive) Has(key string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.keys[key]
+}
+
+// Len returns the number of archived videos
+func (a *Archive) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.keys)
+}
+
+// Add records a downloaded video, appending it to the file if it is new
+func (a *Archive) Add(key string) error {
+	if key == "" {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys[key] {
+		return nil
+	}
+	if err := appendArchive(a.path, []string{key}); err != nil {
+		return err
+	}
+	a.keys[key] = true
+	return nil
+}
+
+// Import merges an existing yt-dlp archive file and returns how many videos
+// were new
+func (a *Archive) Import(path string) (int, error) {
+	keys, err := readArchive(path)
+	if err != nil {
+		return 0, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var added []string
+	for key := range keys {
+		if !a.keys[key] {
+			added = append(added, key)
+		}
+	}
+	if err := appendArchive(a.path, added); err != nil {
+		return 0, err
+	}
+	for _, key := range added {
+		a.keys[key] = true
+	}
+	return len(added), nil
+}
+
+func readArchive(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		keys[ArchiveKey(fields[0], fields[1])] = true
+	}
+	return keys, scanner.Err()
+}
+
+func appendArchive(path string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, key := range keys {
+		if _, err := fmt.Fprintln(f, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveKey builds the key yt-dlp writes to its archive: the extractor in
+// lower case and the video ID
+func ArchiveKey(extractor string, videoID string) string {
+	if extractor == "" || videoID == "" {
+		return ""
+	}
+	return strings.ToLower(extractor) + " " + videoID
+}
+
+// ArchiveKey returns the download's key in the archive, "" until the video's
+// extractor and ID are known
+func (dl *Download) ArchiveKey() string {
+	return ArchiveKey(dl.Platform, dl.VideoID)
+}
+
+// urlArchiveKey recognises the video ID in common URL forms so duplicates can
+// be caught before any metadata is fetched
+func urlArchiveKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	switch siteKey(rawURL) {
+	case "youtube.com":
+		if strings.EqualFold(u.Hostname(), "youtu.be") {
+			return ArchiveKey("youtube", strings.Trim(u.Path, "/"))
+		}
+		if id := u.Query().Get("v"); id != "" && strings.Trim(u.Path, "/") == "watch" {
+			return ArchiveKey("youtube", id)
+		}
+		for _, prefix := range []string{"/shorts/", "/embed/", "/live/"} {
+			if id, ok := strings.CutPrefix(u.Path, prefix); ok {
+				return ArchiveKey("youtube", strings.Trim(id, "/"))
+			}
+		}
+	}
+	return ""
+}
+
+// checkDuplicate applies the job's duplicate policy when its video is in the
+// archive. It returns errDuplicate when the job should not download.
+func (d *Downloader) checkDuplicate(dl *Download) error {
+	d.mu.RLock()
+	opts := dl.Options
+	key := dl.ArchiveKey()
+	if key == "" {
+		key = urlArchiveKey(dl.URL)
+	}
+	d.mu.RUnlock()
+
+	if d.Archive == nil || key == "" || opts.Duplicates == DuplicateRedownload || !d.Archive.Has(key) {
+		return nil
+	}
+
+	var earlier *Download
+	if d.FindDownloaded != nil {
+		earlier = d.FindDownloaded(key)
+	}
+
+	if opts.Duplicates == DuplicateLink {
+		// Without the earlier files on disk there is nothing to link to
+		if earlier == nil || !fileExists(earlier.FilePath) {
+			return nil
+		}
+		d.mu.Lock()
+		dl.FilePath = earlier.FilePath
+		dl.MediaFiles = earlier.MediaFiles
+		dl.SubtitleFiles = earlier.SubtitleFiles
+		dl.ThumbFiles = earlier.ThumbFiles
+		dl.SubtitleCount = earlier.SubtitleCount
+		dl.FileSize = earlier.FileSize
+		dl.Checksums = earlier.Checksums
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	dl.Duplicate = true
+	dl.DuplicateOf = ""
+	if earlier != nil {
+		dl.DuplicateOf = earlier.ID
+		if dl.Title == "" {
+			dl.Title = earlier.Title
+		}
+	}
+	d.mu.Unlock()
+	return errDuplicate
+}
+
+// archiveArgs passes the archive to yt-dlp for skipping jobs. Jobs with the
+// other policies only get here when they must download (no earlier files to
+// link to, or re-downloading on purpose), so yt-dlp must not skip them.
+func (d *Downloader) archiveArgs(opts DownloadOptions) []string {
+	if d.Archive == nil || opts.Duplicates == DuplicateRedownload || opts.Duplicates == DuplicateLink {
+		return nil
+	}
+	return []string{"--download-archive", d.Archive.Path()}
+}
+
+// recordArchive adds a finished download to the archive; yt-dlp may have
+// written the line already
+func (d *Downloader) recordArchive(dl *Download) {
+	if d.Archive == nil {
+		return
+	}
+	if err := d.Archive.Load(); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to reload download archive: %v", err)
+	}
+
+	d.mu.RLock()
+	key := dl.ArchiveKey()
+	d.mu.RUnlock()
+	if err := d.Archive.Add(key); err != nil {
+		log.Printf("Failed to update download archive: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+)
+
+// Checksum records a file as it was when its download completed, so that a
+// later library check can tell whether it was moved, truncated or changed
+type Checksum struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// FileChecksum hashes the file at path
+func FileChecksum(path string) (Checksum, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return Checksum{}, err
+	}
+	return Checksum{Path: path, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// checksumFiles hashes every file a download produced, skipping (and
+// logging) the ones that cannot be read
+func checksumFiles(paths []string) []Checksum {
+	var sums []Checksum
+	for _, path := range paths {
+		sum, err := FileChecksum(path)
+		if err != nil {
+			log.Printf("Could not checksum %s: %v", path, err)
+			continue
+		}
+		sums = append(sums, sum)
+	}
+	return sums
+}
@@ -0,0 +1,115 @@
+package downloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorKind classifies why a yt-dlp run failed
+type ErrorKind string
+
+const (
+	ErrThrottled      ErrorKind = "throttled"       // HTTP 429 / rate limited
+	ErrGeoBlocked     ErrorKind = "geo_blocked"     // Not available in this country
+	ErrUnavailable    ErrorKind = "unavailable"     // Private, removed or never existed
+	ErrLoginRequired  ErrorKind = "login_required"  // Age-gated, members-only or bot check
+	ErrForbidden      ErrorKind = "forbidden"       // HTTP 403, usually fingerprinting
+	ErrUnsupportedURL ErrorKind = "unsupported_url" // No extractor for the URL
+	ErrNetwork        ErrorKind = "network"         // Connection reset, timeout, DNS
+	ErrFFmpegMissing  ErrorKind = "ffmpeg_missing"  // Merge/convert needs ffmpeg
+	ErrDiskFull       ErrorKind = "disk_full"
+	ErrUnknown        ErrorKind = "unknown"
+)
+
+// Transient reports whether retrying the same request later may succeed
+func (k ErrorKind) Transient() bool {
+	return k == ErrThrottled || k == ErrNetwork
+}
+
+// Blocking reports whether the site refused us, as opposed to the content
+// being gone; these are worth retrying with stronger anti-blocking settings
+func (k ErrorKind) Blocking() bool {
+	switch k {
+	case ErrThrottled, ErrForbidden, ErrLoginRequired, ErrGeoBlocked:
+		return true
+	}
+	return false
+}
+
+// Output fragments identifying each kind, checked in order (lower case)
+var errorPatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+	message  string
+}{
+	{ErrDiskFull, []string{"no space left on device", "errno 28", "disk full"},
+		"Not enough disk space"},
+	{ErrFFmpegMissing, []string{"ffmpeg not found", "ffprobe and ffmpeg not found", "ffmpeg is not installed", "ffprobe not found"},
+		"ffmpeg is required but was not found"},
+	{ErrUnsupportedURL, []string{"unsupported url", "is not a valid url"},
+		"This URL is not supported"},
+	{ErrGeoBlocked, []string{"available in your country", "geo restrict", "geo-restrict", "geo_bypass", "not available from your location"},
+		"Video is not available in your country"},
+	{ErrLoginRequired, []string{"sign in to confirm", "age-restricted", "age restricted", "login required", "requires authentication", "members-only", "use --cookies", "account authentication is required", "not a bot"},
+		"Login or browser cookies required"},
+	// YouTube answers a throttled session with "Video unavailable. This
+	// content isn't available, try again later", so these come first
+	{ErrThrottled, []string{"http error 429", "too many requests", "rate-limit", "rate limit", "try again later"},
+		"Too many requests, the site is throttling downloads"},
+	{ErrUnavailable, []string{"private video", "video is private", "video unavailable", "has been removed", "been terminated", "does not exist", "http error 404", "no longer available"},
+		"Video is private, removed or unavailable"},
+	{ErrForbidden, []string{"http error 403", "forbidden"},
+		"Access denied by the site (HTTP 403)"},
+	{ErrNetwork, []string{"connection reset", "timed out", "temporary failure in name resolution", "network is unreachable", "connection refused", "incompleteread", "remote end closed connection", "connection aborted", "urlopen error", "unable to download webpage", "more expected"},
+		"Network error"},
+}
+
+// DownloadError is a classified yt-dlp failure. Error() returns a short
+// human-readable message; the full output is kept in Output for logging.
+type DownloadError struct {
+	Kind    ErrorKind
+	Message string
+	Output  string
+	Err     error
+}
+
+func (e *DownloadError) Error() string {
+	return e.Message
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError turns yt-dlp's output into a DownloadError
+func classifyError(output string, err error) *DownloadError {
+	lower := strings.ToLower(output)
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				return &DownloadError{Kind: p.kind, Message: p.message, Output: output, Err: err}
+			}
+		}
+	}
+
+	// Unknown: surface yt-dlp's own last error line
+	msg := fmt.Sprintf("yt-dlp failed: %v", err)
+	if line := lastErrorLine(output); line != "" {
+		msg = line
+	}
+	return &DownloadError{Kind: ErrUnknown, Message: msg, Output: output, Err: err}
+}
+
+// lastErrorLine returns the last "ERROR:" line of yt-dlp's output, shortened
+func lastErrorLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "ERROR: "); ok {
+			if len(line) > 200 {
+				line = line[:200] + "..."
+			}
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		output   string
+		want     ErrorKind
+		blocking bool
+	}{
+		{"ERROR: [youtube] abc: Sign in to confirm you're not a bot. Use --cookies-from-browser", ErrLoginRequired, true},
+		{"ERROR: [youtube] abc: Video unavailable. This content isn't available, try again later.", ErrThrottled, true},
+		{"ERROR: [youtube] abc: Video unavailable. The current session has been rate-limited by YouTube", ErrThrottled, true},
+		{"ERROR: [youtube] abc: Video unavailable. This video has been removed by the uploader", ErrUnavailable, false},
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access", ErrUnavailable, false},
+		{"ERROR: [youtube] abc: Video unavailable", ErrUnavailable, false},
+		{"ERROR: [youtube] abc: The uploader has not made this video available in your country", ErrGeoBlocked, true},
+		{"ERROR: unable to download video data: HTTP Error 403: Forbidden", ErrForbidden, true},
+		{"ERROR: unable to download video data: HTTP Error 429: Too Many Requests", ErrThrottled, true},
+		{"[download] Got error: HTTP Error 403: Forbidden. Retrying (1/10)...", ErrForbidden, true},
+		{"[download] Got error: 1024 bytes read, 4096 more expected. Retrying", ErrNetwork, false},
+		{"ERROR: [generic] Unable to download webpage: <urlopen error [Errno -3] Temporary failure in name resolution>", ErrNetwork, false},
+		{"ERROR: [Errno 28] No space left on device", ErrDiskFull, false},
+		{"ERROR: Postprocessing: ffprobe and ffmpeg not found", ErrFFmpegMissing, false},
+		{"ERROR: Unsupported URL: http://example.com", ErrUnsupportedURL, false},
+		{"[download] Got error: something odd\nERROR: fragment 3 not found", ErrUnknown, false},
+	} {
+		got := classifyError(tc.output, errors.New("exit status 1"))
+		if got.Kind != tc.want {
+			t.Errorf("%q: kind = %s, want %s", tc.output, got.Kind, tc.want)
+		}
+		if got.Kind.Blocking() != tc.blocking {
+			t.Errorf("%q: blocking = %v, want %v", tc.output, got.Kind.Blocking(), tc.blocking)
+		}
+	}
+}
+
+func TestClassifyErrorUnknownMessage(t *testing.T) {
+	err := classifyError("[info] abc\nERROR: first\nWARNING: x\nERROR: fragment 3 not found\n", errors.New("exit status 1"))
+	if err.Message != "fragment 3 not found" {
+		t.Errorf("message = %q, want the last ERROR line", err.Message)
+	}
+	if err := classifyError("", errors.New("exit status 2")); err.Message != "yt-dlp failed: exit status 2" {
+		t.Errorf("message = %q, want the exit error", err.Message)
+	}
+}
@@ -0,0 +1,154 @@
+package downloader
+
+import (
+	"net/url"
+	"strings"
+)
+
+// escalationStep strengthens the anti-blocking settings of a job by one level
+// and returns a short description of what it changed ("" when it changed
+// nothing, e.g. impersonation was already on)
+type escalationStep func(opts *DownloadOptions) string
+
+// escalationLadder lists the steps in the order they are tried: TLS
+// impersonation, browser cookies, the nightly engine, then each configured
+// proxy in turn. Steps are cumulative.
+func (d *Downloader) escalationLadder() []escalationStep {
+	ladder := []escalationStep{
+		func(opts *DownloadOptions) string {
+			if opts.Impersonate != "" {
+				return ""
+			}
+			opts.Impersonate = "chrome"
+			return "impersonate=chrome"
+		},
+		func(opts *DownloadOptions) string {
+			if opts.UseCookies {
+				return ""
+			}
+			opts.UseCookies = true
+			if opts.BrowserName == "" {
+				opts.BrowserName = "chrome"
+			}
+			return "cookies=" + opts.BrowserName
+		},
+		func(opts *DownloadOptions) string {
+			if opts.UseNightly {
+				return ""
+			}
+			opts.UseNightly = true
+			return "nightly"
+		},
+	}
+
+	d.mu.RLock()
+	proxies := d.Proxies
+	d.mu.RUnlock()
+	for _, proxy := range proxies {
+		ladder = append(ladder, func(opts *DownloadOptions) string {
+			opts.ProxyURL = proxy
+			return "proxy=" + proxy
+		})
+	}
+	return ladder
+}
+
+// SetProxies replaces the proxy list used by the last escalation steps
+func (d *Downloader) SetProxies(proxies []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Proxies = append([]string(nil), proxies...)
+}
+
+// escalate applies the first level steps of the ladder to opts and returns
+// the escalated options with the list of changes made
+func (d *Downloader) escalate(opts DownloadOptions, level int) (DownloadOptions, []string) {
+	var applied []string
+	for i, step := range d.escalationLadder() {
+		if i >= level {
+			break
+		}
+		if change := step(&opts); change != "" {
+			applied = append(applied, change)
+		}
+	}
+	return opts, applied
+}
+
+// maxEscalation is the highest level the ladder currently supports
+func (d *Downloader) maxEscalation() int {
+	return len(d.escalationLadder())
+}
+
+// Hosts that are the same site under another name
+var siteAliases = map[string]string{
+	"youtu.be":             "youtube.com",
+	"youtube-nocookie.com": "youtube.com",
+	"vm.tiktok.com":        "tiktok.com",
+	"x.com":                "twitter.com",
+	"instagr.am":           "instagram.com",
+}
+
+// siteKey identifies the site of a URL for per-site escalation memory
+func siteKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, prefix := range []string{"www.", "m.", "music."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	if alias, ok := siteAliases[host]; ok {
+		return alias
+	}
+	return host
+}
+
+// SiteLevel returns the escalation level future jobs for a URL's site start at
+func (d *Downloader) SiteLevel(rawURL string) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.siteLevels[siteKey(rawURL)]
+}
+
+// SiteLevels returns a copy of the per-site escalation memory
+func (d *Downloader) SiteLevels() map[string]int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	levels := make(map[string]int, len(d.siteLevels))
+	for site, level := range d.siteLevels {
+		levels[site] = level
+	}
+	return levels
+}
+
+// SetSiteLevels replaces the per-site escalation memory, e.g. with one saved
+// by a previous run
+func (d *Downloader) SetSiteLevels(levels map[string]int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.siteLevels = make(map[string]int, len(levels))
+	for site, level := range levels {
+		d.siteLevels[site] = level
+	}
+}
+
+// rememberSiteLevel records that level worked for the URL's site
+func (d *Downloader) rememberSiteLevel(rawURL string, level int) {
+	site := siteKey(rawURL)
+	if site == "" {
+		return
+	}
+
+	d.mu.Lock()
+	changed := level > d.siteLevels[site]
+	if changed {
+		d.siteLevels[site] = level
+	}
+	d.mu.Unlock()
+
+	if changed && d.OnSiteLevel != nil {
+		d.OnSiteLevel(site, level)
+	}
+}
@@ -0,0 +1,143 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType names a download lifecycle event
+type EventType string
+
+const (
+	EventQueued    EventType = "queued"
+	EventStarted   EventType = "started"
+	EventProgress  EventType = "progress" // Throttled to progressInterval per download
+	EventPhase     EventType = "phase"    // Phase or stream changed
+	EventUpdated   EventType = "updated"  // Metadata or output files became known
+	EventPaused    EventType = "paused"
+	EventCompleted EventType = "completed"
+	EventFailed    EventType = "failed"
+	EventCancelled EventType = "cancelled"
+	EventSkipped   EventType = "skipped" // Already downloaded
+)
+
+// progressInterval limits how often progress events are published per download
+const progressInterval = 250 * time.Millisecond
+
+// maxBacklog is the number of undelivered events after which a slow
+// subscriber starts losing progress events (lifecycle events are kept)
+const maxBacklog = 256
+
+// Event is a snapshot of a download at the time something happened to it
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Download Download  `json:"download"`
+}
+
+// subscriber buffers events for one consumer so that publishing never blocks
+// the workers, however slowly the consumer reads
+type subscriber struct {
+	ch      chan Event
+	mu      sync.Mutex
+	pending []Event
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func (s *subscriber) push(ev Event) {
+	s.mu.Lock()
+	if len(s.pending) >= maxBacklog && ev.Type == EventProgress {
+		s.mu.Unlock()
+		return
+	}
+	s.pending = append(s.pending, ev)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) pump() {
+	defer close(s.ch)
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		batch := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		for _, ev := range batch {
+			select {
+			case s.ch <- ev:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel of download events and a function that ends the
+// subscription (and closes the channel). Events arrive in publish order.
+func (d *Downloader) Subscribe() (<-chan Event, func()) {
+	s := &subscriber{
+		ch:   make(chan Event),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	d.subsMu.Lock()
+	d.nextSub++
+	key := d.nextSub
+	d.subs[key] = s
+	d.subsMu.Unlock()
+
+	go s.pump()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			d.subsMu.Lock()
+			delete(d.subs, key)
+			d.subsMu.Unlock()
+			close(s.done)
+		})
+	}
+}
+
+// Subscribers returns the number of active subscriptions
+func (d *Downloader) Subscribers() int {
+	d.subsMu.Lock()
+	defer d.subsMu.Unlock()
+	return len(d.subs)
+}
+
+// publish sends a snapshot of dl to every subscriber. It must be called
+// without d.mu held.
+func (d *Downloader) publish(typ EventType, dl *Download) {
+	// Snapshot while holding subsMu so subscribers see events in order
+	d.subsMu.Lock()
+	defer d.subsMu.Unlock()
+
+	d.mu.Lock()
+	if typ == EventProgress {
+		if time.Since(dl.lastProgress) < progressInterval {
+			d.mu.Unlock()
+			return
+		}
+		dl.lastProgress = time.Now()
+	}
+	ev := Event{Type: typ, Time: time.Now(), Download: *dl}
+	d.mu.Unlock()
+
+	for _, s := range d.subs {
+		s.push(ev)
+	}
+}
@@ -0,0 +1,144 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is one entry of the format table yt-dlp reports for a video
+type Format struct {
+	ID             string  `json:"format_id"`
+	Ext            string  `json:"ext"`
+	Note           string  `json:"format_note"`
+	Resolution     string  `json:"resolution"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	FPS            float64 `json:"fps"`
+	VCodec         string  `json:"vcodec"`
+	ACodec         string  `json:"acodec"`
+	TBR            float64 `json:"tbr"` // Total bitrate in KBit/s
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx"`
+	Protocol       string  `json:"protocol"`
+}
+
+// HasVideo reports whether the format carries a video stream
+func (f Format) HasVideo() bool {
+	return f.VCodec != "" && f.VCodec != "none"
+}
+
+// HasAudio reports whether the format carries an audio stream
+func (f Format) HasAudio() bool {
+	return f.ACodec != "" && f.ACodec != "none"
+}
+
+// Size returns the exact size when known, otherwise yt-dlp's estimate
+func (f Format) Size() int64 {
+	if f.Filesize > 0 {
+		return f.Filesize
+	}
+	return f.FilesizeApprox
+}
+
+// ListFormats returns the formats available for a single video
+func (d *Downloader) ListFormats(ctx context.Context, url string, opts DownloadOptions) ([]Format, error) {
+	info, err := d.FetchInfo(ctx, url, opts)
+	if err != nil {
+		return nil, err
+	}
+	if info.Type == "playlist" {
+		return nil, fmt.Errorf("%s is a playlist, list formats of a single entry instead", url)
+	}
+	return info.Formats, nil
+}
+
+// Heights returns the distinct video heights in formats, highest first, so
+// callers can offer them as "1080p", "720p", ... presets
+func Heights(formats []Format) []int {
+	seen := make(map[int]bool)
+	var heights []int
+	for _, f := range formats {
+		if f.HasVideo() && f.Height > 0 && !seen[f.Height] {
+			seen[f.Height] = true
+			heights = append(heights, f.Height)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(heights)))
+	return heights
+}
+
+// Audio containers accepted in VideoFormat when AudioOnly is set
+var audioFormats = map[string]bool{
+	"mp3": true, "m4a": true, "aac": true, "opus": true,
+	"vorbis": true, "flac": true, "wav": true, "alac": true,
+}
+
+// Named presets in addition to "<N>p"
+var heightPresets = map[string]int{
+	"4k": 2160,
+	"8k": 4320,
+}
+
+var rePreset = regexp.MustCompile(`^(\d{3,4})p$`)
+
+// ResolveFormat turns DownloadOptions.Format into a yt-dlp selector.
+// Presets ("best", "1080p", "audio", ...) are expanded; anything else is
+// treated as a raw selector and passed through unchanged.
+func ResolveFormat(opts DownloadOptions) string {
+	preset := strings.ToLower(strings.TrimSpace(opts.Format))
+
+	if opts.AudioOnly || preset == "audio" {
+		return "bestaudio/best"
+	}
+
+	if preset == "" || preset == "best" {
+		return "bestvideo+bestaudio/best"
+	}
+
+	height, ok := heightPresets[preset]
+	if m := rePreset.FindStringSubmatch(preset); m != nil {
+		height, _ = strconv.Atoi(m[1])
+		ok = true
+	}
+	if ok {
+		// Best stream at or below the requested height, falling back to a
+		// progressive file and finally to whatever is available
+		return fmt.Sprintf("bestvideo[height<=%d]+bestaudio/best[height<=%d]/best", height, height)
+	}
+
+	return opts.Format
+}
+
+// formatArgs builds the quality/container arguments for yt-dlp
+func formatArgs(opts DownloadOptions) []string {
+	args := []string{"--format", ResolveFormat(opts)}
+
+	container := strings.ToLower(opts.VideoFormat)
+	audioOnly := opts.AudioOnly || strings.EqualFold(opts.Format, "audio")
+
+	if audioOnly {
+		// Extract to an audio file; VideoFormat may name the audio codec
+		args = append(args, "--extract-audio")
+		if audioFormats[container] {
+			args = append(args, "--audio-format", container)
+		}
+		return args
+	}
+
+	if container != "" {
+		// Prefer streams that fit the container, then merge/remux into it
+		switch container {
+		case "mp4":
+			args = append(args, "--format-sort", "ext:mp4:m4a")
+		case "webm":
+			args = append(args, "--format-sort", "ext:webm:webm")
+		}
+		args = append(args, "--merge-output-format", container)
+		args = append(args, "--remux-video", container)
+	}
+	return args
+}
@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveFormat(t *testing.T) {
+	for _, tc := range []struct {
+		opts DownloadOptions
+		want string
+	}{
+		{DownloadOptions{}, "bestvideo+bestaudio/best"},
+		{DownloadOptions{Format: "best"}, "bestvideo+bestaudio/best"},
+		{DownloadOptions{Format: " Best "}, "bestvideo+bestaudio/best"},
+		{DownloadOptions{Format: "audio"}, "bestaudio/best"},
+		{DownloadOptions{Format: "1080p", AudioOnly: true}, "bestaudio/best"},
+		{DownloadOptions{Format: "720p"}, "bestvideo[height<=720]+bestaudio/best[height<=720]/best"},
+		{DownloadOptions{Format: "1440P"}, "bestvideo[height<=1440]+bestaudio/best[height<=1440]/best"},
+		{DownloadOptions{Format: "4k"}, "bestvideo[height<=2160]+bestaudio/best[height<=2160]/best"},
+		{DownloadOptions{Format: "137+140"}, "137+140"},
+		{DownloadOptions{Format: "bv*[ext=mp4]+ba"}, "bv*[ext=mp4]+ba"},
+		{DownloadOptions{Format: "12p"}, "12p"},
+	} {
+		if got := ResolveFormat(tc.opts); got != tc.want {
+			t.Errorf("%q (audio only %v): got %q, want %q", tc.opts.Format, tc.opts.AudioOnly, got, tc.want)
+		}
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	for _, tc := range []struct {
+		opts DownloadOptions
+		want []string
+	}{
+		{DownloadOptions{Format: "audio", VideoFormat: "mp3"},
+			[]string{"--format", "bestaudio/best", "--extract-audio", "--audio-format", "mp3"}},
+		{DownloadOptions{AudioOnly: true, VideoFormat: "mp4"},
+			[]string{"--format", "bestaudio/best", "--extract-audio"}},
+		{DownloadOptions{Format: "best", VideoFormat: "mp4"},
+			[]string{"--format", "bestvideo+bestaudio/best", "--format-sort", "ext:mp4:m4a", "--merge-output-format", "mp4", "--remux-video", "mp4"}},
+	} {
+		if got := formatArgs(tc.opts); !slices.Equal(got, tc.want) {
+			t.Errorf("%q as %q: got %q, want %q", tc.opts.Format, tc.opts.VideoFormat, got, tc.want)
+		}
+	}
+}
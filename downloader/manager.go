@@ -2,34 +2,114 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 )
 
 // Download represents the state of a single download
 type Download struct {
-	ID            string          `json:"id"`
-	URL           string          `json:"url"`
-	Title         string          `json:"title"`
-	Platform      string          `json:"platform"`
-	Status        string          `json:"status"` // pending, downloading, merging, completed, failed
-	Progress      float64         `json:"progress"`
-	Speed         string          `json:"speed"`
-	ETA           string          `json:"eta"`
-	FileSize      int64           `json:"file_size"`
-	Downloaded    int64           `json:"downloaded"`
-	FilePath      string          `json:"file_path"`
-	Thumbnail     string          `json:"thumbnail"`
-	Duration      int             `json:"duration"`
-	Quality       string          `json:"quality"`
-	Format        string          `json:"format"`
-	SubtitleCount int             `json:"subtitle_count"`
-	SubtitleLangs []string        `json:"subtitle_langs"`
-	CreatedAt     time.Time       `json:"created_at"`
-	CompletedAt   time.Time       `json:"completed_at"`
-	Error         string          `json:"error"`
-	Options       DownloadOptions `json:"options"` // Store options for retry/resume
+	ID            string    `json:"id"`
+	URL           string    `json:"url"`
+	Title         string    `json:"title"`
+	Platform      string    `json:"platform"`
+	Status        string    `json:"status"`   // pending, downloading, merging, paused, completed, failed, cancelled, skipped
+	Progress      float64   `json:"progress"` // Overall, across all phases
+	Phase         string    `json:"phase"`
+	PhaseProgress float64   `json:"phase_progress"`
+	Stream        int       `json:"stream"`  // 1-based stream being downloaded
+	Streams       int       `json:"streams"` // Streams expected (video, audio, ...)
+	Speed         string    `json:"speed"`
+	SpeedBps      float64   `json:"speed_bps"`
+	ETA           string    `json:"eta"`
+	ETASeconds    int       `json:"eta_seconds"`
+	Fragment      int       `json:"fragment"`  // Current fragment of HLS/DASH streams
+	Fragments     int       `json:"fragments"` // Fragment count, 0 when not fragmented
+	FileSize      int64     `json:"file_size"`
+	Downloaded    int64     `json:"downloaded"`
+	FilePath      string    `json:"file_path"`       // Final path of the (first) media file
+	MediaFiles    []string  `json:"media_files"`     // Every media file produced (playlists yield several)
+	SubtitleFiles []string  `json:"subtitle_files"`  // External subtitle files kept next to the media
+	ThumbFiles    []string  `json:"thumbnail_files"` // Thumbnail images written next to the media
+	Thumbnail     string    `json:"thumbnail"`
+	Duration      int       `json:"duration"`
+	Quality       string    `json:"quality"`
+	Format        string    `json:"format"`
+	SubtitleCount int       `json:"subtitle_count"`
+	SubtitleLangs []string  `json:"subtitle_langs"`
+	CreatedAt     time.Time `json:"created_at"`
+	CompletedAt   time.Time `json:"completed_at"`
+	Error         string    `json:"error"`      // Short human-readable reason
+	ErrorKind     string    `json:"error_kind"` // See ErrorKind
+	Attempts      int       `json:"attempts"`   // yt-dlp runs in the current try, including retries
+
+	// Anti-blocking escalation (Options.AutoEscalate) of the last run
+	EscalationLevel int             `json:"escalation_level"`
+	Escalation      []string        `json:"escalation"`           // Settings applied on top of Options, e.g. "cookies=chrome"
+	PartFiles       []string        `json:"part_files,omitempty"` // Destinations yt-dlp is writing, removed on cancel
+	Options         DownloadOptions `json:"options"`              // Store options for retry/resume
+
+	// Playlists and channels are expanded into one child download per entry
+	ParentID      string   `json:"parent_id,omitempty"`      // Collection this download is an entry of
+	PlaylistIndex int      `json:"playlist_index,omitempty"` // 1-based position in the parent playlist
+	Children      []string `json:"children,omitempty"`       // Entry downloads of a collection, in playlist order
+	ItemsDone     int      `json:"items_done"`               // Children finished (completed, failed or cancelled)
+	ItemsFailed   int      `json:"items_failed"`
+
+	// Duplicate detection, see Archive
+	VideoID     string `json:"video_id,omitempty"`     // Extractor's ID of the video, with Platform the archive key
+	Duplicate   bool   `json:"duplicate"`              // Already archived, not downloaded again (Options.Duplicates)
+	DuplicateOf string `json:"duplicate_of,omitempty"` // ID of the earlier download, when known
+
+	TransferTime float64 `json:"transfer_seconds,omitempty"` // Seconds the yt-dlp download ran, summed over runs; no metadata, queueing or retry waits
+
+	Checksums []Checksum `json:"checksums,omitempty"` // Size and hash of each file at completion, see storage.Verify
+
+	metadata     bool      // Metadata fetched for a single video; playlists expand instead
+	streamSizes  []int64   // Expected bytes per stream, weights overall progress
+	streamFile   string    // File of the stream currently downloading
+	streamBytes  int64     // Bytes of streamFile downloaded so far
+	doneBytes    int64     // Bytes of the streams already finished
+	lastProgress time.Time // When the last progress event was published
+}
+
+// Download statuses
+const (
+	StatusPending     = "pending"
+	StatusDownloading = "downloading"
+	StatusMerging     = "merging"
+	StatusPaused      = "paused"
+	StatusCompleted   = "completed"
+	StatusFailed      = "failed"
+	StatusCancelled   = "cancelled"
+	StatusSkipped     = "skipped" // Already downloaded, see DownloadOptions.Duplicates
+)
+
+// Finished reports whether the download has reached a final state
+func (dl *Download) Finished() bool {
+	switch dl.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusSkipped:
+		return true
+	}
+	return false
+}
+
+// Files returns every file the download produced: media, subtitles and
+// thumbnails
+func (dl *Download) Files() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, list := range [][]string{{dl.FilePath}, dl.MediaFiles, dl.SubtitleFiles, dl.ThumbFiles} {
+		for _, path := range list {
+			if path != "" && !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+		}
+	}
+	return files
 }
 
 // DownloadOptions configures the download parameters
@@ -50,11 +130,14 @@ type DownloadOptions struct {
 	// Output settings
 	OutputDir      string `json:"output_dir"`
 	OutputTemplate string `json:"output_template"`
+	WriteThumbnail bool   `json:"write_thumbnail"`
 
 	// Download behavior
-	NoPlaylist    bool `json:"no_playlist"`
-	PlaylistStart int  `json:"playlist_start"`
-	PlaylistEnd   int  `json:"playlist_end"`
+	NoPlaylist    bool   `json:"no_playlist"`
+	PlaylistStart int    `json:"playlist_start"`
+	PlaylistEnd   int    `json:"playlist_end"`
+	PlaylistItems string `json:"playlist_items"` // Entries to keep, e.g. "1-3,7,10-"; overrides start/end
+	Duplicates    string `json:"duplicates"`     // Videos already archived: "skip" (default), "redownload", "link"
 
 	// Anti-Blocking / Advanced
 	UseCookies  bool   `json:"use_cookies"`
@@ -64,6 +147,10 @@ type DownloadOptions struct {
 	ProxyURL    string `json:"proxy_url"`
 	Impersonate string `json:"impersonate"` // e.g. "chrome"
 
+	// Retry blocked downloads with stronger settings (impersonation,
+	// cookies, nightly engine, proxies), remembering what worked per site
+	AutoEscalate bool `json:"auto_escalate"`
+
 	// Auto-Update
 	AutoUpdateYtdlp bool `json:"auto_update_ytdlp"`
 	UseNightly      bool `json:"use_nightly"`
@@ -73,85 +160,403 @@ type DownloadOptions struct {
 type Downloader struct {
 	mu         sync.RWMutex
 	downloads  map[string]*Download
-	queue      chan string // Queue of download IDs to process
+	cancels    map[string]context.CancelFunc // Per-job cancel funcs of running downloads
+	queue      chan string                   // Queue of download IDs to process
 	max        int
 	OnComplete func(*Download) // Callback for persistence
+	OnChange   func(*Download) // Callback on every state change (queue persistence)
 	BinPath    string          // Path to yt-dlp binary
 	Updater    *Updater
+
+	MaxAttempts int           // yt-dlp runs per job for transient errors
+	RetryDelay  time.Duration // First retry delay, doubled on every attempt
+
+	Proxies     []string                     // Last rungs of the escalation ladder, tried in order
+	OnSiteLevel func(site string, level int) // Called when a site needs a higher escalation level
+	siteLevels  map[string]int               // Escalation level that worked, per site
+
+	Archive        *Archive                   // Videos downloaded so far; nil disables duplicate detection
+	FindDownloaded func(key string) *Download // Earlier download of an archived video, for DuplicateLink
+
+	subsMu  sync.Mutex
+	subs    map[int]*subscriber // Event subscribers, see Subscribe
+	nextSub int
+
+	running sync.WaitGroup // Workers and OnComplete callbacks, see Wait
 }
 
 func NewDownloader(maxConcurrent int) *Downloader {
 	return &Downloader{
-		downloads: make(map[string]*Download),
-		queue:     make(chan string, 100), // Buffer for queue
-		max:       maxConcurrent,
+		downloads:  make(map[string]*Download),
+		cancels:    make(map[string]context.CancelFunc),
+		subs:       make(map[int]*subscriber),
+		siteLevels: make(map[string]int),
+		queue:      make(chan string, 100), // Buffer for queue
+		max:        maxConcurrent,
+
+		MaxAttempts: 3,
+		RetryDelay:  5 * time.Second,
 	}
 }
 
 // Start initializes the worker pool
 func (d *Downloader) Start(ctx context.Context) {
 	for i := 0; i < d.max; i++ {
+		d.running.Add(1)
 		go d.worker(ctx)
 	}
 }
 
+// Wait blocks until the workers have stopped, which they do once the context
+// given to Start ends, and every OnComplete callback has returned. Callers
+// then know nothing writes to their stores any more.
+func (d *Downloader) Wait() {
+	d.running.Wait()
+}
+
+// completed runs OnComplete in the background, tracked by Wait
+func (d *Downloader) completed(snapshot *Download) {
+	if d.OnComplete == nil {
+		return
+	}
+	d.running.Add(1)
+	go func() {
+		defer d.running.Done()
+		d.OnComplete(snapshot)
+	}()
+}
+
 func (d *Downloader) worker(ctx context.Context) {
-	for id := range d.queue {
+	defer d.running.Done()
+	for {
+		var id string
+		select {
+		case id = <-d.queue:
+		case <-ctx.Done():
+			return
+		}
+
 		// Identify download
 		dl := d.GetDownload(id)
 		if dl == nil {
 			continue
 		}
 
-		// Update status
-		d.mu.Lock()
-		dl.Status = "downloading"
-		d.mu.Unlock()
+		// Skip jobs cancelled or paused while waiting in the queue
+		jobCtx, ok := d.begin(ctx, dl)
+		if !ok {
+			continue
+		}
+
+		d.notify(dl, EventStarted)
 
 		// Execute
-		err := d.downloadWithSubtitles(ctx, dl.ID, dl.Options)
+		err := d.downloadWithRetry(jobCtx, dl)
+		if err != nil && ctx.Err() != nil && !errors.Is(err, errExpanded) && !errors.Is(err, errDuplicate) {
+			// Shutting down, not a failure of the job
+			d.interrupt(dl)
+			continue
+		}
+		d.finish(dl, err)
+	}
+}
 
-		d.mu.Lock()
-		dl.CompletedAt = time.Now()
-		if err != nil {
-			dl.Status = "failed"
-			dl.Error = err.Error()
-		} else {
-			dl.Status = "completed"
-			dl.Progress = 1.0
+// notify hands a snapshot of the download to OnChange and publishes it to
+// subscribers as an event of the given type. It must be called without d.mu
+// held.
+func (d *Downloader) notify(dl *Download, typ EventType) {
+	if d.OnChange != nil {
+		d.mu.RLock()
+		snapshot := *dl
+		d.mu.RUnlock()
+		d.OnChange(&snapshot)
+	}
+	d.publish(typ, dl)
+	d.childChanged(dl, typ)
+}
+
+// begin marks a pending download as running and gives it its own cancellable
+// context, so that Cancel and Pause can stop it without touching other jobs.
+func (d *Downloader) begin(ctx context.Context, dl *Download) (context.Context, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if dl.Status != StatusPending {
+		return nil, false
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	d.cancels[dl.ID] = cancel
+	dl.Status = StatusDownloading
+	dl.Error = ""
+	dl.ErrorKind = ""
+	dl.Attempts = 0
+	return jobCtx, true
+}
+
+// finish records the outcome of a run started with begin. Paused jobs keep
+// their partial files for Resume; cancelled jobs have them removed.
+func (d *Downloader) finish(dl *Download, err error) {
+	d.mu.Lock()
+	if cancel, ok := d.cancels[dl.ID]; ok {
+		cancel()
+		delete(d.cancels, dl.ID)
+	}
+
+	switch {
+	case errors.Is(err, errExpanded):
+		// Now a collection, finished by its children
+		d.mu.Unlock()
+		d.notify(dl, EventUpdated)
+		return
+	case err == nil:
+		dl.Status = StatusCompleted
+		dl.Progress = 1.0
+		dl.Error = ""
+		dl.ErrorKind = ""
+	case errors.Is(err, errDuplicate):
+		dl.Status = StatusSkipped
+		if dl.Options.Duplicates == DuplicateLink {
+			// Linked to the earlier download's files
+			dl.Status = StatusCompleted
+		}
+		dl.Progress = 1.0
+		dl.Error = ""
+		dl.ErrorKind = ""
+	case dl.Status == StatusPaused:
+		d.mu.Unlock()
+		d.notify(dl, EventPaused)
+		return
+	case dl.Status == StatusCancelled:
+		// Reported below
+	default:
+		dl.Status = StatusFailed
+		dl.Error = err.Error()
+		dl.ErrorKind = string(ErrUnknown)
+		var dlErr *DownloadError
+		if errors.As(err, &dlErr) {
+			dl.ErrorKind = string(dlErr.Kind)
+			log.Printf("Download %s failed (%s) after %d attempt(s):\n%s", dl.ID, dlErr.Kind, dl.Attempts, dlErr.Output)
+		}
+	}
+
+	dl.CompletedAt = time.Now()
+	dl.Phase = ""
+	dl.Speed = ""
+	dl.ETA = ""
+	if dl.Status == StatusCancelled {
+		removePartials(dl.PartFiles)
+	}
+	dl.PartFiles = nil
+	snapshot := *dl
+	d.mu.Unlock()
+
+	switch snapshot.Status {
+	case StatusCompleted:
+		d.notify(dl, EventCompleted)
+	case StatusCancelled:
+		d.notify(dl, EventCancelled)
+	case StatusSkipped:
+		d.notify(dl, EventSkipped)
+	default:
+		d.notify(dl, EventFailed)
+	}
+
+	d.completed(&snapshot)
+}
+
+// interrupt puts a job stopped by shutdown back to pending, keeping its
+// partial files, so that it stays in the persisted queue and Restore resumes
+// it on the next run. Jobs paused or cancelled meanwhile keep that status.
+func (d *Downloader) interrupt(dl *Download) {
+	d.mu.Lock()
+	if cancel, ok := d.cancels[dl.ID]; ok {
+		cancel()
+		delete(d.cancels, dl.ID)
+	}
+	switch dl.Status {
+	case StatusPaused:
+		d.mu.Unlock()
+		d.notify(dl, EventPaused)
+		return
+	case StatusCancelled:
+		d.mu.Unlock()
+		d.finish(dl, context.Canceled)
+		return
+	}
+	dl.Status = StatusPending
+	dl.Phase = ""
+	dl.Speed = ""
+	dl.ETA = ""
+	d.mu.Unlock()
+	d.notify(dl, EventQueued)
+}
+
+// Cancel stops a queued, running or paused download and removes its partial files
+func (d *Downloader) Cancel(id string) error {
+	d.mu.Lock()
+	dl, ok := d.downloads[id]
+	if !ok {
+		d.mu.Unlock()
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	if dl.Finished() {
+		d.mu.Unlock()
+		return fmt.Errorf("download %s is already %s", id, dl.Status)
+	}
+	if dl.IsCollection() {
+		children := dl.Children
+		d.mu.Unlock()
+		return d.eachChild(children, d.Cancel)
+	}
+
+	dl.Status = StatusCancelled
+	if cancel, running := d.cancels[id]; running {
+		// The worker notices the status and cleans up in finish
+		d.mu.Unlock()
+		cancel()
+		return nil
+	}
+	d.mu.Unlock()
+
+	// Pending or paused: nothing is running, so finish it here
+	d.finish(dl, context.Canceled)
+	return nil
+}
+
+// Pause stops a queued or running download, keeping yt-dlp's .part files so
+// that Resume continues from where it left off
+func (d *Downloader) Pause(id string) error {
+	d.mu.Lock()
+	dl, ok := d.downloads[id]
+	if !ok {
+		d.mu.Unlock()
+		return fmt.Errorf("download not found: %s", id)
+	}
+	if dl.IsCollection() && !dl.Finished() {
+		children := dl.Children
+		d.mu.Unlock()
+		return d.eachChild(children, d.Pause)
+	}
+
+	switch dl.Status {
+	case StatusPending, StatusDownloading, StatusMerging:
+	default:
+		d.mu.Unlock()
+		return fmt.Errorf("download %s cannot be paused while %s", id, dl.Status)
+	}
+
+	dl.Status = StatusPaused
+	dl.Speed = ""
+	dl.ETA = ""
+	cancel := d.cancels[id]
+	d.mu.Unlock()
+
+	if cancel != nil {
+		// The worker notifies once the process has exited
+		cancel()
+		return nil
+	}
+	d.notify(dl, EventPaused)
+	return nil
+}
+
+// Resume re-queues a paused download
+func (d *Downloader) Resume(id string) error {
+	d.mu.Lock()
+	dl, ok := d.downloads[id]
+	if !ok {
+		d.mu.Unlock()
+		return fmt.Errorf("download not found: %s", id)
+	}
+	if dl.IsCollection() && !dl.Finished() {
+		children := dl.Children
+		d.mu.Unlock()
+		return d.eachChild(children, d.Resume)
+	}
+	if dl.Status != StatusPaused {
+		d.mu.Unlock()
+		return fmt.Errorf("download %s is not paused", id)
+	}
+	if _, running := d.cancels[id]; running {
+		d.mu.Unlock()
+		return fmt.Errorf("download %s is still stopping, try again", id)
+	}
+
+	dl.Status = StatusPending
+	d.mu.Unlock()
+
+	d.notify(dl, EventQueued)
+	go func() {
+		d.queue <- id
+	}()
+	return nil
+}
+
+// Restore re-adds downloads persisted by a previous run. Jobs that were
+// pending or interrupted mid-download are queued again and continue from
+// their .part files; paused jobs stay paused until resumed. Collections are
+// not run again, their state follows from the restored children.
+func (d *Downloader) Restore(downloads []Download) {
+	var collections []string
+	for i := range downloads {
+		dl := downloads[i]
+		if dl.Finished() {
+			continue
 		}
 
-		// Callback if set
-		if d.OnComplete != nil {
-			go d.OnComplete(dl)
+		d.mu.Lock()
+		if _, exists := d.downloads[dl.ID]; exists {
+			d.mu.Unlock()
+			continue
+		}
+		if dl.Status != StatusPaused {
+			dl.Status = StatusPending
+		}
+		dl.Speed = ""
+		dl.ETA = ""
+		d.downloads[dl.ID] = &dl
+		queued := dl.Status == StatusPending && !dl.IsCollection()
+		id := dl.ID
+		if dl.IsCollection() {
+			collections = append(collections, id)
 		}
 		d.mu.Unlock()
+
+		if queued {
+			d.publish(EventQueued, &dl)
+			go func() {
+				d.queue <- id
+			}()
+		}
+	}
+
+	for _, id := range collections {
+		d.updateCollection(id, false)
 	}
 }
 
 // QueueDownload adds a download to the queue
 func (d *Downloader) QueueDownload(url string, opts DownloadOptions) string {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	dl := d.AddDownload(url, opts)
+	d.enqueue(dl)
+	return dl.ID
+}
 
-	id := fmt.Sprintf("dl_%d", time.Now().UnixNano())
-	dl := &Download{
-		ID:            id,
-		URL:           url,
-		Status:        "pending",
-		CreatedAt:     time.Now(),
-		SubtitleLangs: opts.SubtitleLangs,
-		Quality:       opts.Format,
-		Options:       opts,
+// enqueue hands a pending download to the workers, or settles it right away
+// when its URL already identifies an archived video
+func (d *Downloader) enqueue(dl *Download) {
+	if errors.Is(d.checkDuplicate(dl), errDuplicate) {
+		d.finish(dl, errDuplicate)
+		return
 	}
-	d.downloads[id] = dl
 
 	// Push to queue (non-blocking if buffer not full, but we should handle it)
+	id := dl.ID
 	go func() {
 		d.queue <- id
 	}()
-
-	return id
 }
 
 // GetDownload retrieves a download by ID safely
@@ -167,41 +572,84 @@ func (d *Downloader) GetDownload(id string) *Download {
 // AddDownload adds a new download to the map and returns its ID
 func (d *Downloader) AddDownload(url string, opts DownloadOptions) *Download {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	dl := newDownload(fmt.Sprintf("dl_%d", time.Now().UnixNano()), url, opts)
+	d.downloads[dl.ID] = dl
+	d.mu.Unlock()
+
+	d.notify(dl, EventQueued)
+	return dl
+}
 
-	id := fmt.Sprintf("dl_%d", time.Now().UnixNano())
-	dl := &Download{
+func newDownload(id string, url string, opts DownloadOptions) *Download {
+	return &Download{
 		ID:            id,
 		URL:           url,
-		Status:        "pending",
+		Status:        StatusPending,
 		CreatedAt:     time.Now(),
 		SubtitleLangs: opts.SubtitleLangs,
 		Quality:       opts.Format,
 		Options:       opts,
 	}
-	d.downloads[id] = dl
-	return dl
 }
 
 // DownloadSynchronously aids the CLI by running the download immediately and blocking
 func (d *Downloader) DownloadSynchronously(ctx context.Context, url string, opts DownloadOptions) (*Download, error) {
 	dl := d.AddDownload(url, opts)
-	dl.Status = "downloading"
+	return dl, d.Run(ctx, dl.ID)
+}
+
+// Run executes a pending download added with AddDownload and blocks until it
+// finishes, is paused or is cancelled
+func (d *Downloader) Run(ctx context.Context, id string) error {
+	dl := d.GetDownload(id)
+	if dl == nil {
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	jobCtx, ok := d.begin(ctx, dl)
+	if !ok {
+		return fmt.Errorf("download %s is not pending", id)
+	}
+
+	d.notify(dl, EventStarted)
 
 	// Delegate to the internal download implementation
 	// Note: downloadWithSubtitles updates the dl object directly
-	err := d.downloadWithSubtitles(ctx, dl.ID, opts)
-	return dl, err
+	err := d.downloadWithRetry(jobCtx, dl)
+	d.finish(dl, err)
+	if errors.Is(err, errDuplicate) {
+		// Skipped or linked per Options.Duplicates, not a failure
+		return nil
+	}
+	return err
 }
 
-// GetProgress returns safe copy of progress fields
-func (d *Downloader) GetProgress(id string) (float64, string, string) {
+// GetProgress returns a safe copy of the progress fields
+func (d *Downloader) GetProgress(id string) Progress {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 	if dl, ok := d.downloads[id]; ok {
-		return dl.Progress, dl.ETA, dl.Status
+		return Progress{
+			Status:        dl.Status,
+			Phase:         dl.Phase,
+			Progress:      dl.Progress,
+			PhaseProgress: dl.PhaseProgress,
+			Stream:        dl.Stream,
+			Streams:       dl.Streams,
+			Speed:         dl.Speed,
+			SpeedBps:      dl.SpeedBps,
+			ETA:           dl.ETA,
+			ETASeconds:    dl.ETASeconds,
+			Fragment:      dl.Fragment,
+			Fragments:     dl.Fragments,
+			Attempts:      dl.Attempts,
+			Error:         dl.Error,
+			Items:         len(dl.Children),
+			ItemsDone:     dl.ItemsDone,
+			ItemsFailed:   dl.ItemsFailed,
+		}
 	}
-	return 0, "", ""
+	return Progress{}
 }
 
 // GetAllDownloads returns all downloads in key-random order
@@ -0,0 +1,120 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+)
+
+// VideoInfo is the subset of yt-dlp's --dump-single-json output VidFetch uses
+type VideoInfo struct {
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Uploader       string  `json:"uploader"`
+	Thumbnail      string  `json:"thumbnail"`
+	Duration       float64 `json:"duration"`
+	UploadDate     string  `json:"upload_date"`
+	Extractor      string  `json:"extractor_key"`
+	WebpageURL     string  `json:"webpage_url"`
+	Type           string  `json:"_type"`  // "video", "playlist", ...
+	Format         string  `json:"format"` // Description of the selected format(s)
+	PlaylistCount  int     `json:"playlist_count"`
+	Filesize       int64   `json:"filesize"`
+	FilesizeApprox int64   `json:"filesize_approx"`
+
+	Entries []PlaylistEntry `json:"entries,omitempty"` // Playlists and channels only
+
+	Formats          []Format `json:"formats,omitempty"`
+	RequestedFormats []Format `json:"requested_formats,omitempty"`
+}
+
+// EstimatedSize returns the expected size in bytes of the selected format(s),
+// summing video and audio when yt-dlp will merge separate streams
+func (v *VideoInfo) EstimatedSize() int64 {
+	if len(v.RequestedFormats) > 0 {
+		var total int64
+		for _, f := range v.RequestedFormats {
+			total += f.Size()
+		}
+		return total
+	}
+	if v.Filesize > 0 {
+		return v.Filesize
+	}
+	return v.FilesizeApprox
+}
+
+// FetchInfo asks yt-dlp for a URL's metadata without downloading anything.
+// Playlists are extracted flat so that large channels return quickly; their
+// entries are numbered for use in DownloadOptions.PlaylistItems.
+func (d *Downloader) FetchInfo(ctx context.Context, url string, opts DownloadOptions) (*VideoInfo, error) {
+	binPath, err := d.binary(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--dump-single-json", "--flat-playlist", "--no-warnings"}
+	if opts.NoPlaylist {
+		args = append(args, "--no-playlist")
+	}
+	args = append(args, formatArgs(opts)...)
+	args = append(args, networkArgs(url, opts)...)
+	args = append(args, url)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binPath, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, classifyError(stderr.String(), err)
+	}
+
+	info := &VideoInfo{}
+	if err := json.Unmarshal(out, info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp metadata: %v", err)
+	}
+	for i := range info.Entries {
+		info.Entries[i].Index = i + 1
+	}
+	return info, nil
+}
+
+// fillMetadata populates the descriptive fields of a download from FetchInfo
+// and returns the info, which lists the entries of playlists
+func (d *Downloader) fillMetadata(ctx context.Context, dl *Download, opts DownloadOptions) (*VideoInfo, error) {
+	d.mu.Lock()
+	dl.setPhase(PhaseMetadata, 0)
+	d.mu.Unlock()
+	d.notify(dl, EventPhase)
+
+	info, err := d.FetchInfo(ctx, dl.URL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	dl.VideoID = info.ID
+	dl.Title = info.Title
+	dl.Thumbnail = info.Thumbnail
+	dl.Duration = int(info.Duration)
+	dl.Platform = info.Extractor
+	dl.Format = info.Format
+	if size := info.EstimatedSize(); size > 0 {
+		dl.FileSize = size
+	}
+
+	// Separate video and audio streams are downloaded one after the other
+	dl.Streams = max(len(info.RequestedFormats), 1)
+	dl.streamSizes = nil
+	for _, f := range info.RequestedFormats {
+		dl.streamSizes = append(dl.streamSizes, f.Size())
+	}
+	dl.setPhase(PhaseMetadata, 1)
+	d.mu.Unlock()
+
+	d.notify(dl, EventUpdated)
+	return info, nil
+}
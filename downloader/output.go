@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// outputTemplate is written by yt-dlp once per video after all post-processing
+// and moving is done, so the paths it reports are the final ones
+const outputTemplate = "after_move:%(.{filepath,requested_subtitles,thumbnails})j"
+
+// outputRecord is one line of the --print-to-file output
+type outputRecord struct {
+	Filepath           string `json:"filepath"`
+	RequestedSubtitles map[string]struct {
+		Filepath string `json:"filepath"`
+	} `json:"requested_subtitles"`
+	Thumbnails []struct {
+		Filepath string `json:"filepath"`
+	} `json:"thumbnails"`
+}
+
+// outputFiles lists the files a download left on disk
+type outputFiles struct {
+	Media      []string
+	Subtitles  []string
+	Thumbnails []string
+	SubCount   int // Subtitle tracks fetched, including embedded ones
+}
+
+// outputFilePath returns where yt-dlp should record the produced files of a job
+func outputFilePath(id string) string {
+	return filepath.Join(os.TempDir(), "vidfetch-"+id+"-files.jsonl")
+}
+
+// readOutputFiles parses the --print-to-file output. Subtitle and thumbnail
+// files that were embedded (and therefore deleted) are skipped.
+func readOutputFiles(path string) (outputFiles, error) {
+	var files outputFiles
+
+	f, err := os.Open(path)
+	if err != nil {
+		return files, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec outputRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+
+		if rec.Filepath != "" && !slices.Contains(files.Media, rec.Filepath) {
+			files.Media = append(files.Media, rec.Filepath)
+		}
+		files.SubCount += len(rec.RequestedSubtitles)
+		for _, sub := range rec.RequestedSubtitles {
+			if fileExists(sub.Filepath) {
+				files.Subtitles = append(files.Subtitles, sub.Filepath)
+			}
+		}
+		for _, thumb := range rec.Thumbnails {
+			if fileExists(thumb.Filepath) {
+				files.Thumbnails = append(files.Thumbnails, thumb.Filepath)
+			}
+		}
+	}
+	slices.Sort(files.Subtitles)
+	return files, scanner.Err()
+}
+
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,134 @@
+package downloader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Download phases, finer grained than Status
+const (
+	PhaseMetadata       = "fetching_metadata"
+	PhaseDownloading    = "downloading"
+	PhaseMerging        = "merging"
+	PhaseEmbeddingSubs  = "embedding_subs"
+	PhasePostProcessing = "post_processing"
+	PhaseRetryWait      = "retry_wait" // Waiting before retrying a transient failure
+)
+
+// Share of the overall progress bar given to each phase. Downloading takes the
+// bulk; the post-processing phases report no percentage of their own, so
+// they just move the bar to the start of their band.
+var phaseBands = map[string][2]float64{
+	PhaseMetadata:       {0, 0.02},
+	PhaseDownloading:    {0.02, 0.90},
+	PhaseMerging:        {0.90, 0.95},
+	PhaseEmbeddingSubs:  {0.95, 0.98},
+	PhasePostProcessing: {0.98, 1.0},
+}
+
+// Progress is a point-in-time view of a download for GetProgress
+type Progress struct {
+	Status        string  `json:"status"`
+	Phase         string  `json:"phase"`
+	Progress      float64 `json:"progress"`       // Overall, 0..1 across all phases
+	PhaseProgress float64 `json:"phase_progress"` // Current phase only, 0..1
+	Stream        int     `json:"stream"`         // 1-based stream being downloaded
+	Streams       int     `json:"streams"`        // Streams expected (video, audio, ...)
+	Speed         string  `json:"speed"`
+	SpeedBps      float64 `json:"speed_bps"`
+	ETA           string  `json:"eta"`
+	ETASeconds    int     `json:"eta_seconds"`
+	Fragment      int     `json:"fragment"`
+	Fragments     int     `json:"fragments"`
+	Attempts      int     `json:"attempts"`
+	Error         string  `json:"error"`
+	Items         int     `json:"items"` // Entries of a collection, 0 for single videos
+	ItemsDone     int     `json:"items_done"`
+	ItemsFailed   int     `json:"items_failed"`
+}
+
+// Label describes the phase for display, e.g. "downloading stream 1 of 2"
+func (p Progress) Label() string {
+	if p.Items > 0 && p.Phase != "" {
+		return fmt.Sprintf("%d of %d entries done", p.ItemsDone, p.Items)
+	}
+	switch p.Phase {
+	case PhaseMetadata:
+		return "fetching metadata"
+	case PhaseDownloading:
+		if p.Streams > 1 {
+			return fmt.Sprintf("downloading stream %d of %d", p.Stream, p.Streams)
+		}
+		return "downloading"
+	case PhaseEmbeddingSubs:
+		return "embedding subtitles"
+	case PhaseMerging:
+		return "merging"
+	case PhasePostProcessing:
+		return "post-processing"
+	case PhaseRetryWait:
+		return fmt.Sprintf("retrying after attempt %d: %s", p.Attempts, p.Error)
+	}
+	return p.Status
+}
+
+// setPhase moves a download to phase with the given fraction of that phase
+// done and recomputes the overall progress. Callers hold d.mu.
+func (dl *Download) setPhase(phase string, frac float64) {
+	dl.Phase = phase
+	dl.PhaseProgress = frac
+
+	band := phaseBands[phase]
+	if phase != PhaseDownloading {
+		dl.Progress = band[0] + (band[1]-band[0])*frac
+		return
+	}
+
+	// Split the download band between streams, by expected size when known
+	streams := max(dl.Streams, 1)
+	weights := make([]float64, streams)
+	var total int64
+	if len(dl.streamSizes) == streams {
+		for _, size := range dl.streamSizes {
+			total += size
+		}
+	}
+	for i := range weights {
+		if total > 0 {
+			weights[i] = float64(dl.streamSizes[i]) / float64(total)
+		} else {
+			weights[i] = 1 / float64(streams)
+		}
+	}
+
+	done := 0.0
+	current := min(max(dl.Stream, 1), streams) - 1
+	for i := 0; i < current; i++ {
+		done += weights[i]
+	}
+	done += weights[current] * frac
+
+	dl.Progress = band[0] + (band[1]-band[0])*done
+}
+
+// startStream records that yt-dlp moved on to the next stream of the job
+func (dl *Download) startStream() {
+	dl.Stream++
+	if dl.Stream > dl.Streams {
+		// More streams than announced (e.g. playlists), stop weighting by size
+		dl.Streams = dl.Stream
+		dl.streamSizes = nil
+	}
+	dl.setPhase(PhaseDownloading, 0)
+}
+
+// Subtitle files are fetched through the same downloader as media streams
+var subtitleExts = map[string]bool{
+	".vtt": true, ".srt": true, ".ass": true, ".ssa": true, ".ttml": true,
+	".srv1": true, ".srv2": true, ".srv3": true, ".json3": true, ".lrc": true,
+}
+
+func isSubtitleFile(path string) bool {
+	return subtitleExts[strings.ToLower(filepath.Ext(path))]
+}
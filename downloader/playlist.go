@@ -0,0 +1,360 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlaylistEntry is one item of a flat-extracted playlist or channel
+type PlaylistEntry struct {
+	Index     int     `json:"index"` // 1-based position, as used by PlaylistItems
+	ID        string  `json:"id"`
+	URL       string  `json:"url"`
+	Title     string  `json:"title"`
+	Duration  float64 `json:"duration"`
+	Uploader  string  `json:"uploader"`
+	Extractor string  `json:"ie_key"`
+	Type      string  `json:"_type"` // "url" for flat entries, "playlist" for nested ones
+}
+
+// IsCollection reports whether the entry is itself a playlist, such as the
+// tabs (Videos, Shorts, Live) listed for a channel's root URL
+func (e PlaylistEntry) IsCollection() bool {
+	return e.Type == "playlist" || strings.HasSuffix(e.Extractor, "Tab") || strings.Contains(e.Extractor, "Playlist")
+}
+
+// errExpanded is returned by a run that turned its job into a collection;
+// the job's state is driven by its children from then on
+var errExpanded = errors.New("expanded into a collection")
+
+// playlistArgs passes the playlist selection of opts to yt-dlp
+func playlistArgs(opts DownloadOptions) []string {
+	var args []string
+	if opts.NoPlaylist {
+		args = append(args, "--no-playlist")
+	}
+	if opts.PlaylistItems != "" {
+		args = append(args, "--playlist-items", opts.PlaylistItems)
+	}
+	if opts.PlaylistStart > 0 {
+		args = append(args, "--playlist-start", strconv.Itoa(opts.PlaylistStart))
+	}
+	if opts.PlaylistEnd > 0 {
+		args = append(args, "--playlist-end", strconv.Itoa(opts.PlaylistEnd))
+	}
+	return args
+}
+
+// PlaylistItems formats 1-based entry indices as a PlaylistItems spec
+func PlaylistItems(indices []int) string {
+	parts := make([]string, len(indices))
+	for i, index := range indices {
+		parts[i] = strconv.Itoa(index)
+	}
+	return strings.Join(parts, ",")
+}
+
+// selectEntries returns the entries chosen by opts: PlaylistItems ("1-3,7,10-",
+// with negative indices counting from the end as in "-1" or "-3:") if set,
+// otherwise the PlaylistStart..PlaylistEnd range
+func selectEntries(entries []PlaylistEntry, opts DownloadOptions) ([]PlaylistEntry, error) {
+	if opts.PlaylistItems == "" {
+		start := max(opts.PlaylistStart, 1)
+		end := len(entries)
+		if opts.PlaylistEnd > 0 {
+			end = min(opts.PlaylistEnd, end)
+		}
+		if start > end {
+			return nil, nil
+		}
+		return entries[start-1 : end], nil
+	}
+
+	// index resolves a 1-based or negative index; 0 is not an item
+	index := func(s string) (int, bool) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n == 0 {
+			return 0, false
+		}
+		if n < 0 {
+			n += len(entries) + 1
+		}
+		return n, true
+	}
+
+	var selected []PlaylistEntry
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(opts.PlaylistItems, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := splitItemRange(part)
+		start, end := 1, len(entries)
+		var ok bool
+		if from != "" {
+			if start, ok = index(from); !ok {
+				return nil, fmt.Errorf("invalid playlist item %q", part)
+			}
+		}
+		switch {
+		case !isRange:
+			end = start
+		case to != "":
+			if end, ok = index(to); !ok || end < start {
+				return nil, fmt.Errorf("invalid playlist item range %q", part)
+			}
+		}
+
+		for i := max(start, 1); i <= min(end, len(entries)); i++ {
+			if !seen[i] {
+				seen[i] = true
+				selected = append(selected, entries[i-1])
+			}
+		}
+	}
+	return selected, nil
+}
+
+// splitItemRange splits a PlaylistItems part at its range separator, ":" or
+// a "-" that is not the sign of the first index
+func splitItemRange(part string) (from, to string, isRange bool) {
+	if from, to, ok := strings.Cut(part, ":"); ok {
+		return from, to, true
+	}
+	if i := strings.Index(part[1:], "-"); i >= 0 {
+		return part[:i+1], part[i+2:], true
+	}
+	return part, "", false
+}
+
+// expand turns dl into a collection with one child job per selected entry of
+// the playlist described by info, and queues the children in playlist order.
+// Children get their own progress, status and retries; the collection's are
+// aggregated by updateCollection.
+func (d *Downloader) expand(dl *Download, info *VideoInfo) error {
+	entries, err := selectEntries(info.Entries, dl.Options)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no entries selected from playlist of %d", len(info.Entries))
+	}
+
+	// Entries are single videos; the selection has been applied here
+	opts := dl.Options
+	opts.NoPlaylist = true
+	opts.PlaylistItems = ""
+	opts.PlaylistStart = 0
+	opts.PlaylistEnd = 0
+
+	d.mu.Lock()
+	if dl.Status != StatusDownloading {
+		// Paused or cancelled while fetching the entries
+		d.mu.Unlock()
+		return errors.New("stopped before expanding")
+	}
+
+	var children []*Download
+	for _, entry := range entries {
+		child := newDownload(fmt.Sprintf("%s_%d", dl.ID, entry.Index), entry.URL, opts)
+		child.ParentID = dl.ID
+		child.PlaylistIndex = entry.Index
+		child.Title = entry.Title
+		child.Duration = int(entry.Duration)
+		child.Platform = entry.Extractor
+		child.VideoID = entry.ID
+		d.downloads[child.ID] = child
+		dl.Children = append(dl.Children, child.ID)
+		children = append(children, child)
+	}
+	dl.Streams = 0
+	dl.streamSizes = nil
+	dl.setPhase(PhaseDownloading, 0)
+	d.mu.Unlock()
+
+	var ids []string
+	for _, child := range children {
+		d.notify(child, EventQueued)
+		if errors.Is(d.checkDuplicate(child), errDuplicate) {
+			d.finish(child, errDuplicate)
+			continue
+		}
+		ids = append(ids, child.ID)
+	}
+	go func() {
+		for _, id := range ids {
+			d.queue <- id
+		}
+	}()
+	return errExpanded
+}
+
+// IsCollection reports whether the download is a playlist or channel whose
+// entries were expanded into child downloads
+func (dl *Download) IsCollection() bool {
+	return len(dl.Children) > 0
+}
+
+// Children returns snapshots of a collection's child downloads in playlist order
+func (d *Downloader) Children(id string) []Download {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	dl, ok := d.downloads[id]
+	if !ok {
+		return nil
+	}
+	list := make([]Download, 0, len(dl.Children))
+	for _, childID := range dl.Children {
+		if child, ok := d.downloads[childID]; ok {
+			list = append(list, *child)
+		}
+	}
+	return list
+}
+
+// childChanged refreshes the collection a download belongs to, if any
+func (d *Downloader) childChanged(dl *Download, typ EventType) {
+	if dl.ParentID == "" {
+		return
+	}
+	d.updateCollection(dl.ParentID, typ == EventProgress)
+}
+
+// updateCollection recomputes a collection's status and progress from its
+// children. Children missing from the downloader finished in an earlier run
+// and count as completed.
+func (d *Downloader) updateCollection(id string, progressOnly bool) {
+	d.mu.Lock()
+	dl, ok := d.downloads[id]
+	if !ok || !dl.IsCollection() || dl.Finished() {
+		d.mu.Unlock()
+		return
+	}
+
+	var progress, speed float64
+	var downloaded, size int64
+	done, failed, cancelled, active := 0, 0, 0, 0
+	for _, childID := range dl.Children {
+		child, ok := d.downloads[childID]
+		if !ok {
+			progress++
+			done++
+			continue
+		}
+		downloaded += child.Downloaded
+		size += child.FileSize
+		speed += child.SpeedBps
+		switch child.Status {
+		case StatusCompleted, StatusSkipped:
+			progress++
+			done++
+		case StatusFailed:
+			progress++
+			done++
+			failed++
+		case StatusCancelled:
+			progress++
+			done++
+			cancelled++
+		case StatusPaused:
+			progress += child.Progress
+		default:
+			progress += child.Progress
+			active++
+		}
+	}
+
+	n := len(dl.Children)
+	prevStatus, prevDone, prevFailed := dl.Status, dl.ItemsDone, dl.ItemsFailed
+	dl.Progress = progress / float64(n)
+	dl.PhaseProgress = dl.Progress
+	dl.Downloaded = downloaded
+	dl.FileSize = size
+	dl.ItemsDone = done
+	dl.ItemsFailed = failed
+	dl.SpeedBps = speed
+	dl.Speed = ""
+	if speed > 0 {
+		dl.Speed = FormatBytes(int64(speed)) + "/s"
+	}
+
+	switch {
+	case done < n && active > 0:
+		dl.Status = StatusDownloading
+		dl.Phase = PhaseDownloading
+	case done < n:
+		dl.Status = StatusPaused
+	case cancelled == n:
+		dl.Status = StatusCancelled
+	case failed+cancelled == n:
+		dl.Status = StatusFailed
+		dl.Error = fmt.Sprintf("%d of %d entries failed", failed, n)
+		dl.ErrorKind = string(ErrUnknown)
+	default:
+		dl.Status = StatusCompleted
+		dl.Error = ""
+		if failed > 0 {
+			dl.Error = fmt.Sprintf("%d of %d entries failed", failed, n)
+		}
+	}
+
+	if !dl.Finished() {
+		status := dl.Status
+		changed := status != prevStatus || dl.ItemsDone != prevDone || dl.ItemsFailed != prevFailed
+		d.mu.Unlock()
+		switch {
+		case status != prevStatus && status == StatusPaused:
+			d.notify(dl, EventPaused)
+		case changed:
+			d.notify(dl, EventUpdated)
+		case progressOnly:
+			d.publish(EventProgress, dl)
+			d.childChanged(dl, EventProgress)
+		}
+		return
+	}
+
+	dl.CompletedAt = time.Now()
+	dl.Phase = ""
+	dl.Speed = ""
+	dl.SpeedBps = 0
+	dl.ETA = ""
+	snapshot := *dl
+	d.mu.Unlock()
+
+	switch snapshot.Status {
+	case StatusCompleted:
+		d.notify(dl, EventCompleted)
+	case StatusCancelled:
+		d.notify(dl, EventCancelled)
+	default:
+		d.notify(dl, EventFailed)
+	}
+	d.completed(&snapshot)
+}
+
+// eachChild applies a control action (Cancel, Pause, Resume) to every child
+// of a collection. It fails only when no child accepted the action.
+func (d *Downloader) eachChild(children []string, action func(id string) error) error {
+	var firstErr error
+	accepted := false
+	for _, id := range children {
+		if err := action(id); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		accepted = true
+	}
+	if !accepted && firstErr != nil {
+		return firstErr
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelectEntries(t *testing.T) {
+	entries := make([]PlaylistEntry, 5)
+	for i := range entries {
+		entries[i].Index = i + 1
+	}
+
+	for _, tc := range []struct {
+		name    string
+		opts    DownloadOptions
+		want    []int
+		wantErr bool
+	}{
+		{"everything", DownloadOptions{}, []int{1, 2, 3, 4, 5}, false},
+		{"start and end", DownloadOptions{PlaylistStart: 2, PlaylistEnd: 3}, []int{2, 3}, false},
+		{"end past the last", DownloadOptions{PlaylistEnd: 9}, []int{1, 2, 3, 4, 5}, false},
+		{"start past the end", DownloadOptions{PlaylistStart: 4, PlaylistEnd: 2}, nil, false},
+		{"single items", DownloadOptions{PlaylistItems: "3,1"}, []int{3, 1}, false},
+		{"dash range", DownloadOptions{PlaylistItems: "2-4"}, []int{2, 3, 4}, false},
+		{"colon range", DownloadOptions{PlaylistItems: "2:3"}, []int{2, 3}, false},
+		{"open end", DownloadOptions{PlaylistItems: "4-"}, []int{4, 5}, false},
+		{"open start", DownloadOptions{PlaylistItems: ":2"}, []int{1, 2}, false},
+		{"duplicates once", DownloadOptions{PlaylistItems: "1-2, 2,1"}, []int{1, 2}, false},
+		{"items win over range", DownloadOptions{PlaylistItems: "5", PlaylistStart: 1, PlaylistEnd: 2}, []int{5}, false},
+		{"past the end", DownloadOptions{PlaylistItems: "4-9,7"}, []int{4, 5}, false},
+		{"last", DownloadOptions{PlaylistItems: "-1"}, []int{5}, false},
+		{"last three", DownloadOptions{PlaylistItems: "-3:"}, []int{3, 4, 5}, false},
+		{"negative dash range", DownloadOptions{PlaylistItems: "-3--2"}, []int{3, 4}, false},
+		{"up to second last", DownloadOptions{PlaylistItems: "4:-1"}, []int{4, 5}, false},
+		{"negative before the first", DownloadOptions{PlaylistItems: "-9:2"}, []int{1, 2}, false},
+		{"zero", DownloadOptions{PlaylistItems: "0"}, nil, true},
+		{"not a number", DownloadOptions{PlaylistItems: "a"}, nil, true},
+		{"lone dash", DownloadOptions{PlaylistItems: "-"}, nil, true},
+		{"reversed range", DownloadOptions{PlaylistItems: "4-2"}, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := selectEntries(entries, tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var indices []int
+			for _, e := range got {
+				indices = append(indices, e.Index)
+			}
+			if !slices.Equal(indices, tc.want) {
+				t.Errorf("got %v, want %v", indices, tc.want)
+			}
+		})
+	}
+}
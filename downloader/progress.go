@@ -1,43 +1,205 @@
 package downloader
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
 
-	"github.com/lrstanley/go-ytdlp"
+// Markers of the machine-readable lines requested with --progress-template
+const (
+	downloadProgressPrefix    = "vidfetch-download:"
+	postprocessProgressPrefix = "vidfetch-postprocess:"
 )
 
+// progressArgs asks yt-dlp to print its progress hook dictionaries as JSON,
+// one per line, instead of the human-readable progress bar
+func progressArgs() []string {
+	return []string{
+		"--newline",
+		"--progress",
+		"--progress-template", "download:" + downloadProgressPrefix + "%(progress)j",
+		"--progress-template", "postprocess:" + postprocessProgressPrefix + "%(progress)j",
+	}
+}
+
+// progressUpdate is yt-dlp's download progress hook dictionary
+type progressUpdate struct {
+	Status             string  `json:"status"` // downloading, finished, error
+	DownloadedBytes    int64   `json:"downloaded_bytes"`
+	TotalBytes         int64   `json:"total_bytes"`
+	TotalBytesEstimate float64 `json:"total_bytes_estimate"`
+	Speed              float64 `json:"speed"` // Bytes per second
+	ETA                float64 `json:"eta"`   // Seconds
+	FragmentIndex      int     `json:"fragment_index"`
+	FragmentCount      int     `json:"fragment_count"`
+	Filename           string  `json:"filename"`
+	TmpFilename        string  `json:"tmpfilename"`
+}
+
+// postprocessUpdate is yt-dlp's post-processor progress hook dictionary
+type postprocessUpdate struct {
+	Status        string `json:"status"`        // started, processing, finished
+	Postprocessor string `json:"postprocessor"` // e.g. "Merger", "EmbedSubtitle"
+}
+
+// parseProgressLine feeds a progress-template line to the matching handler.
+// It returns false for ordinary output lines.
+func (d *Downloader) parseProgressLine(id string, line string) bool {
+	if raw, ok := strings.CutPrefix(line, downloadProgressPrefix); ok {
+		var update progressUpdate
+		if err := json.Unmarshal([]byte(raw), &update); err == nil {
+			d.handleProgress(id, update)
+		}
+		return true
+	}
+	if raw, ok := strings.CutPrefix(line, postprocessProgressPrefix); ok {
+		var update postprocessUpdate
+		if err := json.Unmarshal([]byte(raw), &update); err == nil {
+			d.handlePostprocess(id, update)
+		}
+		return true
+	}
+	return false
+}
+
 // handleProgress updates the download state based on the callback from yt-dlp
-func (d *Downloader) handleProgress(id string, update ytdlp.ProgressUpdate) {
+func (d *Downloader) handleProgress(id string, update progressUpdate) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
-
 	dl, ok := d.downloads[id]
 	if !ok {
+		d.mu.Unlock()
+		return
+	}
+	// Output still in the pipe after Pause or Cancel must not revive the job
+	if dl.Status != StatusDownloading && dl.Status != StatusMerging {
+		d.mu.Unlock()
+		return
+	}
+
+	// Subtitles go through the same downloader but are not media streams
+	if isSubtitleFile(update.Filename) {
+		d.mu.Unlock()
 		return
 	}
 
-	dl.Progress = update.Percent()
-	dl.Downloaded = int64(update.DownloadedBytes)
-	dl.FileSize = int64(update.TotalBytes)
+	// A new file means yt-dlp moved on to the next stream
+	changed := false
+	if update.Filename != "" && update.Filename != dl.streamFile {
+		if dl.streamFile != "" {
+			dl.doneBytes += dl.streamBytes
+		}
+		dl.streamFile = update.Filename
+		dl.streamBytes = 0
+		dl.startStream()
+
+		// Remember destinations so Cancel can remove their partial files
+		if !slices.Contains(dl.PartFiles, update.Filename) {
+			dl.PartFiles = append(dl.PartFiles, update.Filename)
+		}
+		changed = true
+	}
+
+	total := update.TotalBytes
+	if total == 0 {
+		total = int64(update.TotalBytesEstimate)
+	}
+
+	frac := 0.0
+	switch {
+	case update.Status == "finished":
+		frac = 1.0
+	case total > 0:
+		frac = float64(update.DownloadedBytes) / float64(total)
+	case update.FragmentCount > 0:
+		frac = float64(update.FragmentIndex) / float64(update.FragmentCount)
+	}
+	dl.setPhase(PhaseDownloading, min(frac, 1.0))
+
+	dl.streamBytes = update.DownloadedBytes
+	dl.Downloaded = dl.doneBytes + update.DownloadedBytes
+	if dl.FileSize == 0 {
+		dl.FileSize = total
+	}
+	dl.Fragment = update.FragmentIndex
+	dl.Fragments = update.FragmentCount
 
-	if update.Status != "" {
-		// Map ytdlp status to our status if useful,
-		// but usually "downloading" is fine until done.
-		// update.Status might be "downloading", "finished", "processing"
+	dl.SpeedBps = update.Speed
+	dl.Speed = ""
+	if update.Speed > 0 {
+		dl.Speed = FormatBytes(int64(update.Speed)) + "/s"
 	}
 
 	// Format ETA
-	eta := update.ETA()
-	if eta > 0 {
-		dl.ETA = eta.String()
+	dl.ETASeconds = int(update.ETA)
+	dl.ETA = ""
+	if update.ETA > 0 {
+		dl.ETA = (time.Duration(update.ETA) * time.Second).String()
+	}
+	d.mu.Unlock()
+
+	if changed {
+		d.notify(dl, EventPhase)
+		return
+	}
+	d.publish(EventProgress, dl)
+	d.childChanged(dl, EventProgress)
+}
+
+// Post-processors reported by yt-dlp and the phase they start
+var postProcessorPhases = map[string]string{
+	"Merger":             PhaseMerging,
+	"EmbedSubtitle":      PhaseEmbeddingSubs,
+	"ExtractAudio":       PhasePostProcessing,
+	"VideoRemuxer":       PhasePostProcessing,
+	"VideoConvertor":     PhasePostProcessing,
+	"EmbedThumbnail":     PhasePostProcessing,
+	"Metadata":           PhasePostProcessing,
+	"FixupM3u8":          PhasePostProcessing,
+	"FixupM4a":           PhasePostProcessing,
+	"FixupStretched":     PhasePostProcessing,
+	"FixupDuplicateMoov": PhasePostProcessing,
+	"MoveFiles":          PhasePostProcessing,
+}
+
+// handlePostprocess moves the download into the phase of a starting post-processor
+func (d *Downloader) handlePostprocess(id string, update postprocessUpdate) {
+	if update.Status != "started" {
+		return
+	}
+	phase, ok := postProcessorPhases[update.Postprocessor]
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	dl, ok := d.downloads[id]
+	if !ok || dl.Phase == phase {
+		d.mu.Unlock()
+		return
+	}
+	// Output still in the pipe after Pause or Cancel must not revive the job
+	if dl.Status != StatusDownloading && dl.Status != StatusMerging {
+		d.mu.Unlock()
+		return
+	}
+	dl.setPhase(phase, 0)
+	dl.Speed = ""
+	dl.SpeedBps = 0
+	dl.ETA = ""
+	dl.ETASeconds = 0
+	if phase == PhaseMerging {
+		dl.Status = StatusMerging
 	}
+	d.mu.Unlock()
 
-	// Speed isn't directly compatible or calculated?
-	// We can leave speed empty for now or calculate it if needed.
-	// For simplicity in Phase 1, just bytes/percent/ETA.
+	d.notify(dl, EventPhase)
 }
 
-func formatBytes(bytes int64) string {
+// FormatBytes prints a byte count for humans, e.g. "1.5 MiB"
+func FormatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
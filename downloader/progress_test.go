@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"testing"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		lines []string
+		check func(t *testing.T, dl *Download)
+	}{
+		{
+			name:  "bytes",
+			lines: []string{`vidfetch-download:{"status":"downloading","downloaded_bytes":250,"total_bytes":1000,"speed":2048,"eta":90,"filename":"v.mp4"}`},
+			check: func(t *testing.T, dl *Download) {
+				if dl.PhaseProgress != 0.25 || dl.Downloaded != 250 || dl.FileSize != 1000 {
+					t.Errorf("progress = %v, %d of %d, want 0.25, 250 of 1000", dl.PhaseProgress, dl.Downloaded, dl.FileSize)
+				}
+				if dl.Speed != "2.0 KiB/s" || dl.ETA != "1m30s" {
+					t.Errorf("speed %q, ETA %q", dl.Speed, dl.ETA)
+				}
+				if len(dl.PartFiles) != 1 || dl.PartFiles[0] != "v.mp4" {
+					t.Errorf("part files = %v", dl.PartFiles)
+				}
+			},
+		},
+		{
+			name:  "estimate",
+			lines: []string{`vidfetch-download:{"status":"downloading","downloaded_bytes":50,"total_bytes_estimate":200.5,"filename":"v.mp4"}`},
+			check: func(t *testing.T, dl *Download) {
+				if dl.FileSize != 200 || dl.PhaseProgress != 0.25 {
+					t.Errorf("size %d, progress %v", dl.FileSize, dl.PhaseProgress)
+				}
+			},
+		},
+		{
+			name:  "fragments",
+			lines: []string{`vidfetch-download:{"status":"downloading","downloaded_bytes":10,"fragment_index":3,"fragment_count":12,"filename":"v.mp4"}`},
+			check: func(t *testing.T, dl *Download) {
+				if dl.PhaseProgress != 0.25 || dl.Fragment != 3 || dl.Fragments != 12 {
+					t.Errorf("progress %v, fragment %d of %d", dl.PhaseProgress, dl.Fragment, dl.Fragments)
+				}
+			},
+		},
+		{
+			name: "second stream",
+			lines: []string{
+				`vidfetch-download:{"status":"finished","downloaded_bytes":300,"total_bytes":300,"filename":"v.f137.mp4"}`,
+				`vidfetch-download:{"status":"downloading","downloaded_bytes":50,"total_bytes":100,"filename":"v.f140.m4a"}`,
+			},
+			check: func(t *testing.T, dl *Download) {
+				if dl.Stream != 2 || dl.Downloaded != 350 || len(dl.PartFiles) != 2 {
+					t.Errorf("stream %d, downloaded %d, part files %v", dl.Stream, dl.Downloaded, dl.PartFiles)
+				}
+			},
+		},
+		{
+			name:  "subtitles ignored",
+			lines: []string{`vidfetch-download:{"status":"downloading","downloaded_bytes":5,"total_bytes":10,"filename":"v.en.vtt"}`},
+			check: func(t *testing.T, dl *Download) {
+				if dl.Downloaded != 0 || len(dl.PartFiles) != 0 {
+					t.Errorf("downloaded %d, part files %v", dl.Downloaded, dl.PartFiles)
+				}
+			},
+		},
+		{
+			name:  "merger",
+			lines: []string{`vidfetch-postprocess:{"status":"started","postprocessor":"Merger"}`},
+			check: func(t *testing.T, dl *Download) {
+				if dl.Status != StatusMerging || dl.Phase != PhaseMerging {
+					t.Errorf("status %s, phase %s", dl.Status, dl.Phase)
+				}
+			},
+		},
+		{
+			name:  "malformed",
+			lines: []string{`vidfetch-download:{"status":`},
+			check: func(t *testing.T, dl *Download) {
+				if dl.Downloaded != 0 || dl.Phase != "" {
+					t.Errorf("downloaded %d, phase %q", dl.Downloaded, dl.Phase)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDownloader(1)
+			dl := &Download{ID: "a", URL: "http://x", Status: StatusDownloading}
+			d.downloads[dl.ID] = dl
+			for _, line := range tc.lines {
+				if !d.parseProgressLine(dl.ID, line) {
+					t.Fatalf("%s: not a progress line", line)
+				}
+			}
+			tc.check(t, dl)
+		})
+	}
+
+	if NewDownloader(1).parseProgressLine("a", "[download] Destination: v.mp4") {
+		t.Error("ordinary output taken for progress")
+	}
+}
+
+func TestProgressAfterStop(t *testing.T) {
+	for _, status := range []string{StatusPaused, StatusCancelled} {
+		d := NewDownloader(1)
+		dl := &Download{ID: "a", URL: "http://x", Status: status}
+		d.downloads[dl.ID] = dl
+
+		d.parseProgressLine(dl.ID, `vidfetch-download:{"status":"downloading","downloaded_bytes":5,"total_bytes":10,"filename":"v.mp4"}`)
+		d.parseProgressLine(dl.ID, `vidfetch-postprocess:{"status":"started","postprocessor":"Merger"}`)
+		if dl.Status != status || dl.Downloaded != 0 || dl.Phase != "" {
+			t.Errorf("%s: status %s, downloaded %d, phase %q; want the job untouched", status, dl.Status, dl.Downloaded, dl.Phase)
+		}
+	}
+}
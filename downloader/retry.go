@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// maxRetryDelay caps the exponential backoff
+const maxRetryDelay = 5 * time.Minute
+
+// downloadWithRetry runs yt-dlp, retrying transient failures (throttling,
+// network errors) with exponential backoff up to d.MaxAttempts runs.
+// With Options.AutoEscalate, blocking failures instead climb the
+// escalation ladder, starting from the level remembered for the site.
+// Permanent failures are returned immediately.
+func (d *Downloader) downloadWithRetry(ctx context.Context, dl *Download) error {
+	level := 0
+	if dl.Options.AutoEscalate {
+		level = d.SiteLevel(dl.URL)
+	}
+
+	retries := 0
+	for attempt := 1; ; attempt++ {
+		opts, applied := d.escalate(dl.Options, level)
+
+		d.mu.Lock()
+		dl.Attempts = attempt
+		dl.EscalationLevel = level
+		dl.Escalation = applied
+		dl.Stream = 0
+		dl.streamFile = ""
+		dl.streamBytes = 0
+		dl.doneBytes = 0
+		d.mu.Unlock()
+
+		err := d.downloadWithSubtitles(ctx, dl.ID, opts)
+		if err == nil {
+			if level > 0 {
+				d.rememberSiteLevel(dl.URL, level)
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		var dlErr *DownloadError
+		if !errors.As(err, &dlErr) {
+			return err
+		}
+
+		var delay time.Duration
+		if next, ok := d.nextEscalation(dl.Options, level); dl.Options.AutoEscalate && dlErr.Kind.Blocking() && ok {
+			// Blocked: step up the ladder, only pausing when throttled
+			level = next
+			if dlErr.Kind == ErrThrottled {
+				delay = retryDelay(d.RetryDelay, 1, dlErr.Kind)
+			}
+		} else {
+			if !dlErr.Kind.Transient() || retries+1 >= d.MaxAttempts {
+				return err
+			}
+			retries++
+			delay = retryDelay(d.RetryDelay, retries, dlErr.Kind)
+		}
+
+		d.mu.Lock()
+		dl.Phase = PhaseRetryWait
+		dl.Error = dlErr.Message
+		dl.ErrorKind = string(dlErr.Kind)
+		dl.Speed = ""
+		dl.ETA = delay.Round(time.Second).String()
+		d.mu.Unlock()
+		d.notify(dl, EventPhase)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// nextEscalation returns the next level above level that actually changes
+// opts, skipping steps the user already enabled by hand
+func (d *Downloader) nextEscalation(opts DownloadOptions, level int) (int, bool) {
+	_, current := d.escalate(opts, level)
+	for next := level + 1; next <= d.maxEscalation(); next++ {
+		if _, applied := d.escalate(opts, next); len(applied) > len(current) {
+			return next, true
+		}
+	}
+	return level, false
+}
+
+// retryDelay returns the wait before the next attempt: base doubled per
+// attempt, longer for throttling, with +-20% jitter
+func retryDelay(base time.Duration, attempt int, kind ErrorKind) time.Duration {
+	if base <= 0 {
+		base = 5 * time.Second
+	}
+	if kind == ErrThrottled {
+		base *= 6
+	}
+	delay := min(base<<(attempt-1), maxRetryDelay)
+	jitter := 0.8 + 0.4*rand.Float64()
+	return time.Duration(float64(delay) * jitter)
+}
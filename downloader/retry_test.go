@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		base    time.Duration
+		attempt int
+		kind    ErrorKind
+		want    time.Duration // Before jitter
+	}{
+		{"first", time.Second, 1, ErrNetwork, time.Second},
+		{"doubles", time.Second, 3, ErrNetwork, 4 * time.Second},
+		{"default base", 0, 1, ErrNetwork, 5 * time.Second},
+		{"throttled waits longer", time.Second, 2, ErrThrottled, 12 * time.Second},
+		{"capped", time.Second, 20, ErrNetwork, maxRetryDelay},
+		{"throttled capped", time.Minute, 3, ErrThrottled, maxRetryDelay},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lo, hi := time.Duration(float64(tc.want)*0.8), time.Duration(float64(tc.want)*1.2)
+			for range 50 {
+				if got := retryDelay(tc.base, tc.attempt, tc.kind); got < lo || got > hi {
+					t.Fatalf("delay = %v, want %v +-20%%", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransient(t *testing.T) {
+	for kind, want := range map[ErrorKind]bool{
+		ErrNetwork:     true,
+		ErrThrottled:   true,
+		ErrForbidden:   false,
+		ErrUnavailable: false,
+		ErrDiskFull:    false,
+		ErrUnknown:     false,
+	} {
+		if got := kind.Transient(); got != want {
+			t.Errorf("%s: transient = %v, want %v", kind, got, want)
+		}
+	}
+}
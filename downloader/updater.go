@@ -3,8 +3,13 @@ package downloader
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,6 +17,9 @@ type Updater struct {
 	BinPath         string
 	lastUpdateCheck time.Time
 	updateInterval  time.Duration
+
+	nightlyMu      sync.Mutex // Held while the nightly copy is created or updated
+	nightlyChecked time.Time
 }
 
 // NewUpdater creates a new updater instance
@@ -30,7 +38,7 @@ func (u *Updater) CheckAndUpdate(ctx context.Context, mode string) (string, erro
 		return "Skipped (recently checked)", nil
 	}
 
-	fmt.Println("Checking for yt-dlp updates...")
+	log.Println("Checking for yt-dlp updates...")
 	u.lastUpdateCheck = time.Now()
 
 	var cmd *exec.Cmd
@@ -55,6 +63,69 @@ func (u *Updater) CheckAndUpdate(ctx context.Context, mode string) (string, erro
 	return fmt.Sprintf("Updated: %s", outStr), nil
 }
 
+// NightlyBinary returns a nightly build of yt-dlp kept next to the managed
+// binary, for jobs that ask for it. It is a separate copy so that switching
+// channels never replaces the binary other jobs are running; it is created
+// on first use and updated at most once per update interval.
+func (u *Updater) NightlyBinary(ctx context.Context) (string, error) {
+	u.nightlyMu.Lock()
+	defer u.nightlyMu.Unlock()
+
+	// In a subdirectory, so the cache fallback of InstallYtDlp never picks it
+	path := filepath.Join(filepath.Dir(u.BinPath), "nightly", filepath.Base(u.BinPath))
+	_, err := os.Stat(path)
+	if err == nil && time.Since(u.nightlyChecked) < u.updateInterval {
+		return path, nil
+	}
+
+	existed := err == nil
+	if !existed {
+		if err := copyExecutable(u.BinPath, path); err != nil {
+			return "", fmt.Errorf("failed to copy yt-dlp: %w", err)
+		}
+	}
+	cmd := exec.CommandContext(ctx, path, "--update-to", "nightly")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if !existed {
+			os.Remove(path) // Still the stable build
+			return "", fmt.Errorf("update failed: %v, output: %s", err, string(output))
+		}
+		// Keep using the nightly build we have, retry next interval
+		log.Printf("Updating nightly yt-dlp failed: %v", err)
+	}
+	u.nightlyChecked = time.Now()
+	return path, nil
+}
+
+// copyExecutable copies src to dst through a temporary file, so dst is
+// either missing or complete
+func copyExecutable(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
 // GetVersion returns the current version of yt-dlp
 func (u *Updater) GetVersion(ctx context.Context) (string, error) {
 	if u.BinPath == "" {
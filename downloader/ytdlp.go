@@ -3,12 +3,12 @@ package downloader
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -39,7 +39,7 @@ func InstallYtDlp(ctx context.Context) (string, error) {
 		name := entry.Name()
 		if strings.HasPrefix(name, "yt-dlp-") && !strings.HasSuffix(name, ".tmp") {
 			fullPath := filepath.Join(ytdlpCache, name)
-			fmt.Printf("Recovered using cached yt-dlp: %s\n", fullPath)
+			log.Printf("Recovered using cached yt-dlp: %s", fullPath)
 			return fullPath, nil
 		}
 	}
@@ -47,43 +47,36 @@ func InstallYtDlp(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("failed to install yt-dlp and no local binary found: %v", err)
 }
 
-// downloadWithSubtitles executes the download using yt-dlp
-func (d *Downloader) downloadWithSubtitles(ctx context.Context, id string, opts DownloadOptions) error {
-	dl := d.GetDownload(id)
-	if dl == nil {
-		return fmt.Errorf("download not found: %s", id)
-	}
-
-	// Determine format
-	format := "bestvideo+bestaudio/best"
-	if opts.Format != "" {
-		format = opts.Format
-	}
-
-	// Locate binary
+// binary returns the yt-dlp executable for a job, installing it if the path
+// is unset
+func (d *Downloader) binary(ctx context.Context, opts DownloadOptions) (string, error) {
 	binPath := d.BinPath
 	if binPath == "" {
 		// Fallback (should not happen if initialized correctly)
 		var err error
 		binPath, err = InstallYtDlp(ctx)
 		if err != nil {
-			return err
+			return "", err
 		}
 		d.BinPath = binPath // cache it
 	}
 
-	// Prepare args
-	var args []string
-
-	// Format
-	args = append(args, "--format", format)
+	// Escalated jobs may ask for the nightly engine, kept as a separate copy
+	if opts.UseNightly && d.Updater != nil {
+		nightly, err := d.Updater.NightlyBinary(ctx)
+		if err == nil {
+			return nightly, nil
+		}
+		log.Printf("Nightly yt-dlp unavailable, using the installed one: %v", err)
+	}
+	return binPath, nil
+}
 
-	// Paths
-	output := filepath.Join(opts.OutputDir, opts.OutputTemplate)
-	args = append(args, "--output", output)
-	args = append(args, "--no-overwrites")
+// networkArgs builds the anti-blocking arguments shared by every yt-dlp call
+// (downloads and metadata lookups alike)
+func networkArgs(url string, opts DownloadOptions) []string {
+	var args []string
 
-	// Networking / Anti-Bot
 	// 1. Cookies (Best method)
 	if opts.UseCookies {
 		browser := opts.BrowserName
@@ -106,20 +99,100 @@ func (d *Downloader) downloadWithSubtitles(ctx context.Context, id string, opts
 		args = append(args, "--proxy", opts.ProxyURL)
 	}
 
-	// 4. Rate Limit
-	if opts.RateLimit != "" {
-		args = append(args, "--limit-rate", opts.RateLimit)
-	}
-
 	// Common headers
 	// Mimic browser aggressively
 	args = append(args, "--add-header", "Accept-Language:en-US,en;q=0.9")
 	args = append(args, "--add-header", "Accept:text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
 	args = append(args, "--add-header", "DNT:1")
 	args = append(args, "--add-header", "Sec-Fetch-Mode:navigate")
-	args = append(args, "--referer", dl.URL)
+	args = append(args, "--referer", url)
 	args = append(args, "--no-check-certificates")
 
+	// Experimental Impersonation (for TLS Fingerprinting)
+	if opts.Impersonate != "" {
+		args = append(args, "--impersonate", opts.Impersonate)
+	}
+
+	return args
+}
+
+// downloadWithSubtitles executes the download using yt-dlp
+func (d *Downloader) downloadWithSubtitles(ctx context.Context, id string, opts DownloadOptions) error {
+	dl := d.GetDownload(id)
+	if dl == nil {
+		return fmt.Errorf("download not found: %s", id)
+	}
+
+	// Locate binary
+	binPath, err := d.binary(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// Fill title, thumbnail etc. before the transfer starts (best effort)
+	if !dl.metadata {
+		info, err := d.fillMetadata(ctx, dl, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// The video itself is gone or unsupported: no point downloading
+			var dlErr *DownloadError
+			if errors.As(err, &dlErr) && (dlErr.Kind == ErrUnavailable || dlErr.Kind == ErrUnsupportedURL) {
+				return err
+			}
+			log.Printf("Metadata fetch for %s failed: %v", dl.URL, err)
+		} else if info.Type == "playlist" && len(info.Entries) > 0 {
+			// Playlists and channels become one child job per entry
+			return d.expand(dl, info)
+		} else {
+			// A playlist stays unmarked until expanded, so a resume lists it again
+			d.mu.Lock()
+			dl.metadata = true
+			d.mu.Unlock()
+		}
+	}
+
+	// Now that the video ID is known, don't fetch it twice
+	if err := d.checkDuplicate(dl); err != nil {
+		return err
+	}
+
+	// Prepare args
+	var args []string
+
+	// Format (presets resolved to yt-dlp selectors)
+	args = append(args, formatArgs(opts)...)
+
+	// Paths
+	output := filepath.Join(opts.OutputDir, opts.OutputTemplate)
+	args = append(args, "--output", output)
+	args = append(args, "--no-overwrites")
+	args = append(args, "--continue") // Pick up .part files left by Pause
+	if opts.WriteThumbnail {
+		args = append(args, "--write-thumbnail")
+	}
+
+	// Record final file paths once post-processing has moved everything
+	filesLog := outputFilePath(id)
+	os.Remove(filesLog)
+	defer os.Remove(filesLog)
+	args = append(args, "--print-to-file", outputTemplate, filesLog)
+
+	// Networking / Anti-Bot
+	args = append(args, networkArgs(dl.URL, opts)...)
+
+	// Playlist selection, for when the entries could not be listed up front
+	args = append(args, playlistArgs(opts)...)
+
+	// Let yt-dlp skip and record archived videos too
+	args = append(args, d.archiveArgs(opts)...)
+
+	// Rate Limit
+	if opts.RateLimit != "" {
+		args = append(args, "--limit-rate", opts.RateLimit)
+	}
+
 	// Subtitles
 	if len(opts.SubtitleLangs) > 0 {
 		args = append(args, "--sub-langs", strings.Join(opts.SubtitleLangs, ","))
@@ -144,22 +217,13 @@ func (d *Downloader) downloadWithSubtitles(ctx context.Context, id string, opts
 	args = append(args, "--no-abort-on-error")
 	args = append(args, "--ignore-errors")
 
-	// Experimental Impersonation (for TLS Fingerprinting)
-	if opts.Impersonate != "" {
-		args = append(args, "--impersonate", opts.Impersonate)
-	}
-
-	args = append(args, "--newline") // Critical for parsing
-	args = append(args, "--progress")
+	// Progress as JSON lines, handled by parseProgressLine
+	args = append(args, progressArgs()...)
 
 	// URL must be last
 	args = append(args, dl.URL)
 
 	// Execute
-	d.mu.Lock()
-	dl.Status = "downloading" // ensure status
-	d.mu.Unlock()
-
 	cmd := exec.CommandContext(ctx, binPath, args...)
 
 	stdout, err := cmd.StdoutPipe()
@@ -173,63 +237,83 @@ func (d *Downloader) downloadWithSubtitles(ctx context.Context, id string, opts
 	cmd.Stderr = cmd.Stdout // Merge them for simplicity in this context
 
 	if err := cmd.Start(); err != nil {
-		d.mu.Lock()
-		dl.Status = "failed"
-		dl.Error = err.Error()
-		d.mu.Unlock()
 		return err
 	}
+	started := time.Now()
 
-	// Parser regex items
-	// [download]  23.5% of 10.00MiB at 2.00MiB/s ETA 00:05
-	reProgress := regexp.MustCompile(`\[download\]\s+(\d+\.?\d*)%`)
-	reETA := regexp.MustCompile(`ETA\s+(\d+:\d+)`)
-	reSpeed := regexp.MustCompile(`at\s+(\d+\.?\d*\w+/s)`)
-
-	// Scan output
 	// Scan output
 	var outputLog strings.Builder
 	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
 		line := scanner.Text()
+		if d.parseProgressLine(id, line) {
+			continue
+		}
 		outputLog.WriteString(line + "\n")
+	}
 
-		// Parse progress
-		if strings.Contains(line, "[download]") {
-			// Extract percent
-			if matches := reProgress.FindStringSubmatch(line); len(matches) > 1 {
-				if p, err := strconv.ParseFloat(matches[1], 64); err == nil {
-					d.mu.Lock()
-					dl.Progress = p / 100.0
-
-					// Extract ETA
-					if etaMatch := reETA.FindStringSubmatch(line); len(etaMatch) > 1 {
-						dl.ETA = etaMatch[1]
-					}
-					// Extract Speed
-					if speedMatch := reSpeed.FindStringSubmatch(line); len(speedMatch) > 1 {
-						dl.Speed = speedMatch[1]
-					}
-
-					d.mu.Unlock()
-				}
-			}
+	err = cmd.Wait()
+	// Only the transfer itself counts, not metadata lookups or retry waits
+	d.mu.Lock()
+	dl.TransferTime += time.Since(started).Seconds()
+	d.mu.Unlock()
+	if err != nil {
+		if ctx.Err() != nil {
+			// Stopped by Cancel or Pause
+			return ctx.Err()
 		}
+		return classifyError(outputLog.String(), err)
 	}
 
-	if err := cmd.Wait(); err != nil {
+	d.recordArchive(dl)
+
+	files, err := readOutputFiles(filesLog)
+	if len(files.Media) == 0 && strings.Contains(outputLog.String(), archivedMarker) {
+		// yt-dlp found the video in the archive and skipped it
 		d.mu.Lock()
-		dl.Status = "failed"
-		dl.Error = outputLog.String() // Provide full output as error
+		dl.Duplicate = true
 		d.mu.Unlock()
-		return fmt.Errorf("yt-dlp error: %v, out: %s", err, outputLog.String())
+		return errDuplicate
+	}
+	if err != nil {
+		log.Printf("Could not determine output files of %s: %v", id, err)
+		return nil
 	}
 
+	// Remember what the files looked like for later integrity checks
+	sums := checksumFiles(append(append(append([]string{}, files.Media...), files.Subtitles...), files.Thumbnails...))
+
 	d.mu.Lock()
-	dl.Status = "completed"
-	dl.Progress = 1.0
-	dl.CompletedAt = time.Now()
+	dl.MediaFiles = files.Media
+	dl.SubtitleFiles = files.Subtitles
+	dl.ThumbFiles = files.Thumbnails
+	dl.SubtitleCount = files.SubCount
+	dl.Checksums = sums
+	if len(files.Media) > 0 {
+		dl.FilePath = files.Media[0]
+		var total int64
+		for _, path := range files.Media {
+			if fi, err := os.Stat(path); err == nil {
+				total += fi.Size()
+			}
+		}
+		dl.FileSize = total
+	}
 	d.mu.Unlock()
 
 	return nil
 }
+
+// removePartials deletes the temporary files yt-dlp leaves next to each
+// destination when it is interrupted (.part, .ytdl and fragment files)
+func removePartials(destinations []string) {
+	for _, dest := range destinations {
+		os.Remove(dest + ".part")
+		os.Remove(dest + ".ytdl")
+		if frags, err := filepath.Glob(dest + ".part-Frag*"); err == nil {
+			for _, frag := range frags {
+				os.Remove(frag)
+			}
+		}
+	}
+}
@@ -30,26 +30,54 @@ function App() {
         output_template: "%(title)s.%(ext)s"
     }))
 
-    const refreshData = async () => {
+    // Lifecycle or progress change of one download, see downloader.Event
+    interface DownloadEvent {
+        type: string
+        time: string
+        download: downloader.Download
+    }
+
+    const refreshQueue = async () => {
         try {
             const q = await GetQueue()
             setQueue(q || [])
+        } catch (e) {
+            console.error("Failed to fetch queue:", e)
+        }
+    }
 
+    const refreshHistory = async () => {
+        try {
             const h = await GetHistory()
             setHistory(h || [])
         } catch (e) {
-            console.error("Failed to fetch data:", e)
+            console.error("Failed to fetch history:", e)
         }
     }
 
     useEffect(() => {
-        // Initial fetch
-        refreshData()
-        // Poll every second
-        const interval = setInterval(refreshData, 1000)
-
-        // Listen for completion events
-        const unsub = EventsOn("download-complete", (dl: downloader.Download) => {
+        // Initial state, then keep it current from pushed events
+        refreshQueue()
+        refreshHistory()
+
+        // Replace the download the event is about, or add it when new
+        const unsubEvents = EventsOn("download-event", (ev: DownloadEvent) => {
+            const dl = downloader.Download.createFrom(ev.download)
+            setQueue(prev => {
+                const i = prev.findIndex(d => d.id === dl.id)
+                if (i === -1) {
+                    return [...prev, dl]
+                }
+                const next = prev.slice()
+                next[i] = dl
+                return next
+            })
+        })
+
+        // Finished downloads are saved to history in the background
+        const unsubHistory = EventsOn("history-updated", refreshHistory)
+
+        const unsubComplete = EventsOn("download-complete", (dl: downloader.Download) => {
             toast.success(`Download complete: ${dl.title || 'Video'}`, {
                 duration: 4000,
                 position: 'bottom-right',
@@ -59,12 +87,12 @@ function App() {
                     border: '1px solid #334155',
                 },
             });
-            refreshData();
         });
 
         return () => {
-            clearInterval(interval);
-            unsub();
+            unsubEvents();
+            unsubHistory();
+            unsubComplete();
         }
     }, [])
 
@@ -75,7 +103,6 @@ function App() {
             const result = await DownloadVideoWithOptions(url, options)
             setStatus(result)
             setUrl('') // Clear input
-            refreshData()
         } catch (e) {
             setStatus('Error: ' + e)
         }
@@ -91,7 +118,6 @@ function App() {
             }
         }
         setStatus(`Queued ${urls.length} downloads.`)
-        refreshData()
     }
 
     return (
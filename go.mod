@@ -5,6 +5,7 @@ go 1.25.5
 require (
 	github.com/lrstanley/go-ytdlp v1.2.7
 	github.com/wailsapp/wails/v2 v2.11.0
+	go.etcd.io/bbolt v1.4.3
 )
 
 require (
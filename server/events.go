@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// heartbeatInterval keeps idle event streams from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+// Snapshot is the first message of an event stream: the current state of the
+// downloads it covers, so a reconnecting client needs nothing else
+type Snapshot struct {
+	Type      string                `json:"type"` // Always "snapshot"
+	Time      time.Time             `json:"time"`
+	Downloads []downloader.Download `json:"downloads"` // Oldest first, playlist entries included
+}
+
+// streamEvents sends download events as Server-Sent Events: a "snapshot"
+// event, then one event per downloader.Event, named after its type. Repeat
+// ?id= to follow only those downloads (and their playlist entries).
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+
+	ids := make(map[string]bool)
+	for _, id := range r.URL.Query()["id"] {
+		ids[id] = true
+	}
+	match := func(dl *downloader.Download) bool {
+		return len(ids) == 0 || ids[dl.ID] || ids[dl.ParentID]
+	}
+
+	// Subscribe before taking the snapshot so nothing falls in between;
+	// events already reflected in it are harmless to repeat
+	events, unsubscribe := s.dlr.Subscribe()
+	defer unsubscribe()
+
+	snapshot := Snapshot{Type: "snapshot", Time: time.Now(), Downloads: []downloader.Download{}}
+	for _, dl := range s.dlr.GetAllDownloads() {
+		if match(&dl) {
+			snapshot.Downloads = append(snapshot.Downloads, dl)
+		}
+	}
+	sortCreated(snapshot.Downloads)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	if err := writeEvent(w, snapshot.Type, snapshot); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if !match(&ev.Download) {
+				continue
+			}
+			if err := writeEvent(w, string(ev.Type), ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one Server-Sent Event with v as JSON data
+func writeEvent(w http.ResponseWriter, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// sseEvent is one parsed Server-Sent Event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// openEvents connects to the event stream with the given query and returns
+// its events as they arrive, and a function that disconnects
+func openEvents(t *testing.T, ts *httptest.Server, query string) (<-chan sseEvent, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/events?"+query, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("events: status = %d", resp.StatusCode)
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev.name != "" {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events, cancel
+}
+
+// nextEvent returns the next event, failing the test after a few seconds
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		return ev
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+// readSnapshot reads the first event, which must be the snapshot
+func readSnapshot(t *testing.T, events <-chan sseEvent) Snapshot {
+	t.Helper()
+	ev := nextEvent(t, events)
+	if ev.name != "snapshot" {
+		t.Fatalf("first event = %s, want snapshot", ev.name)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(ev.data), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+// readUntil reads events until one of type typ for download id arrives and
+// returns every download event read
+func readUntil(t *testing.T, events <-chan sseEvent, id string, typ downloader.EventType) []downloader.Event {
+	t.Helper()
+	var read []downloader.Event
+	for {
+		ev := nextEvent(t, events)
+		var dlEv downloader.Event
+		if err := json.Unmarshal([]byte(ev.data), &dlEv); err != nil {
+			t.Fatalf("%s event: %v", ev.name, err)
+		}
+		if ev.name != string(dlEv.Type) {
+			t.Errorf("event named %s carries type %s", ev.name, dlEv.Type)
+		}
+		read = append(read, dlEv)
+		if dlEv.Download.ID == id && dlEv.Type == typ {
+			return read
+		}
+	}
+}
+
+func TestEventsSnapshotFirst(t *testing.T) {
+	ts := newTestServer(t)
+
+	var done Job
+	call(t, ts, http.MethodPost, "/api/downloads", `{"url": "http://x/v/abc"}`, &done)
+	waitFor(t, "the download to complete", func() bool {
+		call(t, ts, http.MethodGet, "/api/downloads/"+done.ID, "", &done)
+		return done.Finished()
+	})
+
+	events, _ := openEvents(t, ts, "token="+testToken)
+	snapshot := readSnapshot(t, events)
+	if len(snapshot.Downloads) != 1 || snapshot.Downloads[0].ID != done.ID || snapshot.Downloads[0].Status != downloader.StatusCompleted {
+		t.Fatalf("snapshot = %v, want the completed download", snapshot.Downloads)
+	}
+
+	var job Job
+	call(t, ts, http.MethodPost, "/api/downloads", `{"url": "http://x/v/def"}`, &job)
+	read := readUntil(t, events, job.ID, downloader.EventCompleted)
+	if read[0].Type != downloader.EventQueued || read[0].Download.ID != job.ID {
+		t.Errorf("first live event = %s of %s, want queued of %s", read[0].Type, read[0].Download.ID, job.ID)
+	}
+}
+
+func TestEventsFilterByID(t *testing.T) {
+	ts := newTestServer(t)
+
+	var followed, other Job
+	call(t, ts, http.MethodPost, "/api/downloads", `{"url": "http://x/v/slow"}`, &followed)
+
+	events, _ := openEvents(t, ts, "token="+testToken+"&id="+followed.ID)
+	snapshot := readSnapshot(t, events)
+	if len(snapshot.Downloads) != 1 || snapshot.Downloads[0].ID != followed.ID {
+		t.Fatalf("snapshot = %v, want only %s", snapshot.Downloads, followed.ID)
+	}
+
+	call(t, ts, http.MethodPost, "/api/downloads", `{"url": "http://x/v/abc"}`, &other)
+	waitFor(t, "the other download to complete", func() bool {
+		call(t, ts, http.MethodGet, "/api/downloads/"+other.ID, "", &other)
+		return other.Finished()
+	})
+	call(t, ts, http.MethodPost, "/api/downloads/"+followed.ID+"/cancel", "", nil)
+
+	for _, ev := range readUntil(t, events, followed.ID, downloader.EventCancelled) {
+		if ev.Download.ID != followed.ID {
+			t.Errorf("got %s event of %s, want only %s", ev.Type, ev.Download.ID, followed.ID)
+		}
+	}
+}
+
+func TestEventsQueryToken(t *testing.T) {
+	ts := newTestServer(t)
+
+	events, _ := openEvents(t, ts, "token="+testToken)
+	readSnapshot(t, events)
+
+	for _, query := range []string{"", "token=wrong", "token="} {
+		resp, err := http.Get(ts.URL + "/api/events?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("?%s: status = %d, want 401", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestEventsDisconnectUnsubscribes(t *testing.T) {
+	ts, dlr := startTestServer(t)
+
+	events, disconnect := openEvents(t, ts, "token="+testToken)
+	readSnapshot(t, events)
+	if n := dlr.Subscribers(); n != 1 {
+		t.Fatalf("subscribers = %d, want 1", n)
+	}
+
+	disconnect()
+	waitFor(t, "the subscription to end", func() bool {
+		return dlr.Subscribers() == 0
+	})
+}
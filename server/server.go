@@ -0,0 +1,336 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+// maxBody limits request bodies; the largest is a download with its options
+const maxBody = 1 << 20
+
+// Server exposes a Downloader and its history over an HTTP/JSON API. Every
+// request needs the token as "Authorization: Bearer <token>".
+type Server struct {
+	ctx   context.Context // Lifetime of the daemon, for engine updates
+	dlr   *downloader.Downloader
+	hist  storage.HistoryStore
+	token string
+	mux   *http.ServeMux
+
+	// Defaults fill the options a queued download leaves empty
+	Defaults downloader.DownloadOptions
+}
+
+// New returns a server for dlr and hist. The token must not be empty.
+func New(ctx context.Context, dlr *downloader.Downloader, hist storage.HistoryStore, token string) *Server {
+	s := &Server{
+		ctx:   ctx,
+		dlr:   dlr,
+		hist:  hist,
+		token: token,
+		mux:   http.NewServeMux(),
+		Defaults: downloader.DownloadOptions{
+			OutputDir:      "./downloads",
+			OutputTemplate: "%(title)s.%(ext)s",
+			SubtitleLangs:  []string{"all"},
+		},
+	}
+
+	s.mux.HandleFunc("POST /api/downloads", s.queueDownload)
+	s.mux.HandleFunc("GET /api/downloads", s.listDownloads)
+	s.mux.HandleFunc("GET /api/downloads/{id}", s.getDownload)
+	s.mux.HandleFunc("DELETE /api/downloads/{id}", s.control(s.dlr.Cancel))
+	s.mux.HandleFunc("POST /api/downloads/{id}/cancel", s.control(s.dlr.Cancel))
+	s.mux.HandleFunc("POST /api/downloads/{id}/pause", s.control(s.dlr.Pause))
+	s.mux.HandleFunc("POST /api/downloads/{id}/resume", s.control(s.dlr.Resume))
+
+	s.mux.HandleFunc("GET /api/events", s.streamEvents)
+
+	s.mux.HandleFunc("GET /api/history", s.queryHistory)
+	s.mux.HandleFunc("GET /api/history/{id}", s.getHistory)
+	s.mux.HandleFunc("DELETE /api/history/{id}", s.deleteHistory)
+
+	s.mux.HandleFunc("GET /api/engine", s.engineVersion)
+	s.mux.HandleFunc("POST /api/engine/update", s.updateEngine)
+	return s
+}
+
+// ServeHTTP checks the token and routes the request. The event stream also
+// takes it as ?token=, since browsers' EventSource cannot set headers.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && r.URL.Path == "/api/events" {
+		token, ok = r.URL.Query().Get("token"), true
+	}
+	if !ok || s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// queueRequest is the body of POST /api/downloads
+type queueRequest struct {
+	URL     string                     `json:"url"`
+	Options downloader.DownloadOptions `json:"options"`
+	Entries []int                      `json:"entries"` // Playlist entries to download (1-based), all when empty
+}
+
+// Job is a download, with its entries for playlists and channels
+type Job struct {
+	downloader.Download
+	Entries []downloader.Download `json:"entries,omitempty"`
+}
+
+func (s *Server) queueDownload(w http.ResponseWriter, r *http.Request) {
+	var req queueRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.URL == "" {
+		writeError(w, http.StatusBadRequest, errors.New("url is required"))
+		return
+	}
+
+	opts := req.Options
+	if opts.OutputDir == "" {
+		opts.OutputDir = s.Defaults.OutputDir
+	}
+	if opts.OutputTemplate == "" {
+		opts.OutputTemplate = s.Defaults.OutputTemplate
+	}
+	if len(opts.SubtitleLangs) == 0 {
+		opts.SubtitleLangs = s.Defaults.SubtitleLangs
+	}
+	if len(req.Entries) > 0 {
+		opts.PlaylistItems = downloader.PlaylistItems(req.Entries)
+	}
+
+	id := s.dlr.QueueDownload(req.URL, opts)
+	job, _ := s.job(id)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+// listDownloads returns the jobs of this run, oldest first. Playlist entries
+// are left out unless ?entries=true.
+func (s *Server) listDownloads(w http.ResponseWriter, r *http.Request) {
+	entries := r.URL.Query().Get("entries") == "true"
+	list := []downloader.Download{}
+	for _, dl := range s.dlr.GetAllDownloads() {
+		if dl.ParentID == "" || entries {
+			list = append(list, dl)
+		}
+	}
+	sortCreated(list)
+	writeJSON(w, http.StatusOK, list)
+}
+
+// sortCreated orders downloads oldest first
+func sortCreated(list []downloader.Download) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+}
+
+func (s *Server) getDownload(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("download not found: %s", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// job looks up a download of this run
+func (s *Server) job(id string) (Job, bool) {
+	for _, dl := range s.dlr.GetAllDownloads() {
+		if dl.ID != id {
+			continue
+		}
+		job := Job{Download: dl}
+		if dl.IsCollection() {
+			job.Entries = s.dlr.Children(id)
+		}
+		return job, true
+	}
+	return Job{}, false
+}
+
+// control handles the cancel, pause and resume actions
+func (s *Server) control(action func(id string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, ok := s.job(id); !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("download not found: %s", id))
+			return
+		}
+		if err := action(id); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		job, _ := s.job(id)
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// queryHistory takes the storage.Query fields as parameters: status,
+// platform, text, contains, sort, offset, limit, and from/to as dates
+// (2006-01-02) or RFC 3339 times
+func (s *Server) queryHistory(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := storage.Query{
+		Status:   params.Get("status"),
+		Platform: params.Get("platform"),
+		Text:     params.Get("text"),
+		Contains: params.Get("contains"),
+		Sort:     params.Get("sort"),
+	}
+
+	var err error
+	for name, dst := range map[string]*int{"offset": &q.Offset, "limit": &q.Limit} {
+		if v := params.Get(name); v != "" && err == nil {
+			if *dst, err = strconv.Atoi(v); err != nil || *dst < 0 {
+				err = fmt.Errorf("invalid %s: %s", name, v)
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := params.Get(name); v != "" && err == nil {
+			*dst, err = parseTime(v)
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := s.hist.Query(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if page.Downloads == nil {
+		page.Downloads = []downloader.Download{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid time: %s", v)
+	}
+	return t, nil
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	dl := s.hist.Lookup(r.PathValue("id"))
+	if dl == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("history entry not found: %s", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, dl)
+}
+
+// deleteHistory removes an entry, and its files with ?files=true
+func (s *Server) deleteHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.hist.Lookup(id) == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("history entry not found: %s", id))
+		return
+	}
+	dl, err := s.hist.Delete(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if r.URL.Query().Get("files") == "true" {
+		if err := storage.RemoveFiles(dl); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, dl)
+}
+
+func (s *Server) engineVersion(w http.ResponseWriter, r *http.Request) {
+	if s.dlr.Updater == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("engine initializing, please wait"))
+		return
+	}
+	version, err := s.dlr.Updater.GetVersion(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"version": version})
+}
+
+// updateEngine updates yt-dlp; the body may set {"mode": "nightly"}
+func (s *Server) updateEngine(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Mode string `json:"mode"`
+	}{Mode: "stable"}
+	if r.ContentLength != 0 {
+		if err := decodeBody(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if req.Mode != "stable" && req.Mode != "nightly" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown update mode: %s", req.Mode))
+		return
+	}
+	if s.dlr.Updater == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("engine initializing, please wait"))
+		return
+	}
+
+	result, err := s.dlr.Updater.CheckAndUpdate(s.ctx, req.Mode)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"result": result})
+}
+
+// decodeBody reads a JSON body into v, rejecting unknown fields so typos in
+// option names are not silently ignored
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with {"error": "..."}
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	"github.com/shubhambadola/VidFetch/storage"
+)
+
+const testToken = "secret"
+
+// fakeYtDlp answers metadata requests with a fixed video and "downloads" it
+// into dir with a few progress lines. URLs containing "slow" take long
+// enough to be cancelled.
+const fakeYtDlp = `#!/bin/sh
+printfile=""
+prev=""
+for a in "$@"; do
+  if [ "$a" = "--dump-single-json" ]; then
+    echo '{"id":"abc","title":"Fake Video","duration":12,"extractor_key":"Youtube","webpage_url":"http://x","_type":"video"}'
+    exit 0
+  fi
+  if [ "$prev" = "--print-to-file" ]; then prev="template"; continue; fi
+  if [ "$prev" = "template" ]; then printfile="$a"; prev=""; continue; fi
+  prev="$a"
+done
+out="%[1]s/video.mp4"
+steps=5
+case "$*" in *slow*) steps=300 ;; esac
+echo "[download] Destination: $out"
+i=1
+while [ $i -le $steps ]; do
+  echo "vidfetch-download:{\"status\":\"downloading\",\"downloaded_bytes\":$i,\"total_bytes\":$steps,\"speed\":1000,\"eta\":1,\"filename\":\"$out\",\"tmpfilename\":\"$out.part\"}"
+  sleep 0.05
+  i=$((i+1))
+done
+echo "vidfetch-download:{\"status\":\"finished\",\"downloaded_bytes\":$steps,\"total_bytes\":$steps,\"filename\":\"$out\"}"
+echo hello > "$out"
+[ -n "$printfile" ] && echo "{\"filepath\":\"$out\"}" >> "$printfile"
+exit 0
+`
+
+// newTestServer serves a downloader running the fake yt-dlp, with its
+// history in a temporary database
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts, _ := startTestServer(t)
+	return ts
+}
+
+// startTestServer is newTestServer, also returning the downloader
+func startTestServer(t *testing.T) (*httptest.Server, *downloader.Downloader) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake yt-dlp is a shell script")
+	}
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "yt-dlp")
+	if err := os.WriteFile(bin, []byte(fmt.Sprintf(fakeYtDlp, dir)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	hist, err := storage.OpenDB(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dlr := downloader.NewDownloader(2)
+	dlr.BinPath = bin
+	dlr.OnComplete = func(dl *downloader.Download) {
+		if err := hist.Add(*dl); err != nil {
+			t.Errorf("Failed to save history: %v", err)
+		}
+	}
+	dlr.Start(ctx)
+
+	api := New(ctx, dlr, hist, testToken)
+	api.Defaults.OutputDir = dir
+	ts := httptest.NewServer(api)
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+		dlr.Wait()
+		hist.Close()
+	})
+	return ts, dlr
+}
+
+// call sends a request with the token and decodes the JSON response into
+// out (when not nil), returning the status code
+func call(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitFor polls until cond holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"no token", "/api/downloads", "", http.StatusUnauthorized},
+		{"wrong token", "/api/downloads", "Bearer wrong", http.StatusUnauthorized},
+		{"not bearer", "/api/downloads", testToken, http.StatusUnauthorized},
+		{"query token outside events", "/api/downloads?token=" + testToken, "", http.StatusUnauthorized},
+		{"valid token", "/api/downloads", "Bearer " + testToken, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestDownloadLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+
+	var job Job
+	if code := call(t, ts, http.MethodPost, "/api/downloads", `{"url": "http://x/v/abc"}`, &job); code != http.StatusCreated {
+		t.Fatalf("queue: status = %d", code)
+	}
+	if job.ID == "" {
+		t.Fatal("queue: no job ID")
+	}
+
+	waitFor(t, "the download to complete", func() bool {
+		call(t, ts, http.MethodGet, "/api/downloads/"+job.ID, "", &job)
+		return job.Finished()
+	})
+	if job.Status != downloader.StatusCompleted {
+		t.Fatalf("status = %s (%s), want completed", job.Status, job.Error)
+	}
+	if job.Title != "Fake Video" || job.FilePath == "" {
+		t.Errorf("job = %q at %q, want Fake Video with a file", job.Title, job.FilePath)
+	}
+
+	var list []downloader.Download
+	call(t, ts, http.MethodGet, "/api/downloads", "", &list)
+	if len(list) != 1 || list[0].ID != job.ID {
+		t.Errorf("list = %v, want the one job", list)
+	}
+
+	// Recorded by OnComplete in the background
+	var page storage.Page
+	waitFor(t, "the history entry", func() bool {
+		call(t, ts, http.MethodGet, "/api/history?status=completed", "", &page)
+		return page.Total == 1
+	})
+	if page.Downloads[0].ID != job.ID {
+		t.Errorf("history ID = %s, want %s", page.Downloads[0].ID, job.ID)
+	}
+	if code := call(t, ts, http.MethodGet, "/api/history/"+job.ID, "", nil); code != http.StatusOK {
+		t.Errorf("history entry: status = %d", code)
+	}
+	if code := call(t, ts, http.MethodDelete, "/api/history/"+job.ID, "", nil); code != http.StatusOK {
+		t.Errorf("delete history entry: status = %d", code)
+	}
+	if code := call(t, ts, http.MethodGet, "/api/history/"+job.ID, "", nil); code != http.StatusNotFound {
+		t.Errorf("deleted history entry: status = %d, want 404", code)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ts := newTestServer(t)
+
+	var job Job
+	call(t, ts, http.MethodPost, "/api/downloads", `{"url": "http://x/v/slow"}`, &job)
+	waitFor(t, "the download to start", func() bool {
+		call(t, ts, http.MethodGet, "/api/downloads/"+job.ID, "", &job)
+		return job.Status == downloader.StatusDownloading && job.Progress > 0
+	})
+
+	if code := call(t, ts, http.MethodPost, "/api/downloads/"+job.ID+"/cancel", "", &job); code != http.StatusOK {
+		t.Fatalf("cancel: status = %d", code)
+	}
+	waitFor(t, "the download to be cancelled", func() bool {
+		call(t, ts, http.MethodGet, "/api/downloads/"+job.ID, "", &job)
+		return job.Status == downloader.StatusCancelled
+	})
+	if code := call(t, ts, http.MethodPost, "/api/downloads/"+job.ID+"/resume", "", nil); code != http.StatusConflict {
+		t.Errorf("resume cancelled: status = %d, want 409", code)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/downloads", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/downloads", `{"url": "http://x", "typo": 1}`, http.StatusBadRequest},
+		{http.MethodGet, "/api/downloads/missing", "", http.StatusNotFound},
+		{http.MethodPost, "/api/downloads/missing/cancel", "", http.StatusNotFound},
+		{http.MethodGet, "/api/history?limit=-1", "", http.StatusBadRequest},
+		{http.MethodGet, "/api/history/missing", "", http.StatusNotFound},
+	} {
+		if code := call(t, ts, tc.method, tc.path, tc.body, nil); code != tc.want {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.path, code, tc.want)
+		}
+	}
+}
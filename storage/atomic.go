@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupCount is how many previous versions of a state file are kept, as
+// path.bak.1 (newest) to path.bak.N
+const backupCount = 3
+
+// saveJSON writes v to path as indented JSON with writeFileAtomic
+func saveJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// writeFileAtomic replaces path with data so that a crash leaves either the
+// old or the new version, never a truncated file: the data goes to a synced
+// temp file that is renamed over path once the previous versions have been
+// rotated into the backups
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	rotateBackups(path)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// rotateBackups shifts path.bak.1.. up by one and keeps a copy of the current
+// file as path.bak.1. The current file stays in place until it is replaced.
+func rotateBackups(path string) {
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	for i := backupCount - 1; i >= 1; i-- {
+		os.Rename(backupPath(path, i), backupPath(path, i+1))
+	}
+	newest := backupPath(path, 1)
+	os.Remove(newest)
+	if err := os.Link(path, newest); err != nil {
+		// No hard links on this filesystem: copy instead
+		if data, err := os.ReadFile(path); err == nil {
+			os.WriteFile(newest, data, 0644)
+		}
+	}
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.bak.%d", path, n)
+}
+
+// syncDir makes a rename in dir durable; not supported everywhere
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// loadJSON reads path into v. A file that is not valid JSON (e.g. truncated
+// by a crash) is moved aside as path.corrupt-<time> and the newest valid
+// backup is restored in its place. The error wraps os.ErrNotExist when there
+// is nothing to load.
+func loadJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	err = decodeJSON(data, v)
+	if err == nil {
+		return nil
+	}
+	log.Printf("%s is corrupt: %v", path, err)
+
+	quarantined := quarantinePath(path)
+	if err := os.Rename(path, quarantined); err != nil {
+		return fmt.Errorf("failed to quarantine corrupt %s: %w", path, err)
+	}
+	log.Printf("Moved corrupt %s to %s", path, quarantined)
+
+	for i := 1; i <= backupCount; i++ {
+		backup := backupPath(path, i)
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			continue
+		}
+		if err := decodeJSON(data, v); err != nil {
+			log.Printf("Backup %s is corrupt too: %v", backup, err)
+			continue
+		}
+		if err := writeFileAtomic(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to restore %s from %s: %w", path, backup, err)
+		}
+		log.Printf("Restored %s from %s", path, backup)
+		return nil
+	}
+
+	// Start empty; the quarantined file is kept for manual recovery
+	return fmt.Errorf("%s was corrupt and has no valid backup: %w", path, os.ErrNotExist)
+}
+
+// quarantinePath returns an unused path.corrupt-<time> name
+func quarantinePath(path string) string {
+	name := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	candidate := name
+	for i := 2; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// decodeJSON unmarshals data into v after checking it is complete JSON, so a
+// truncated file is never half loaded
+func decodeJSON(data []byte, v any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fmt.Errorf("file is empty")
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("invalid or truncated JSON")
+	}
+	return json.Unmarshal(data, v)
+}
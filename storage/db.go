@@ -0,0 +1,665 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the history database. Index keys end in the big-endian finish
+// time and the download ID, so each index iterates in date order.
+var (
+	metaBucket      = []byte("meta")
+	downloadsBucket = []byte("downloads")    // ID -> Download JSON
+	dateIndex       = []byte("idx_date")     // time|id
+	statusIndex     = []byte("idx_status")   // status\0time|id
+	platformIndex   = []byte("idx_platform") // platform\0time|id
+	wordIndex       = []byte("idx_word")     // word\0time|id
+	videoIndex      = []byte("idx_video")    // archive key\0time|id of completed downloads
+	attemptIndex    = []byte("idx_attempt")  // archive key or URL\0time|id, for CollapseRetries
+
+	schemaVersionKey = []byte("schema_version")
+	importedKey      = []byte("imported_json")
+	retentionKey     = []byte("retention")
+)
+
+// migrations bring the database schema up to date; migrations[i] upgrades
+// from version i to i+1. Append new ones, never edit released ones.
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: downloads and their indexes
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{downloadsBucket, dateIndex, statusIndex, platformIndex, wordIndex, videoIndex, attemptIndex} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// DB keeps finished downloads in an embedded bbolt database with indexes by
+// date, status, platform, title words, archive key and retry group
+type DB struct {
+	db *bolt.DB
+}
+
+// OpenDB opens (or creates) the history database at path and migrates it to
+// the current schema
+func OpenDB(path string) (*DB, error) {
+	// Ensure dir exists
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	s := &DB{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close releases the database file
+func (s *DB) Close() error {
+	return s.db.Close()
+}
+
+// SchemaVersion returns the schema version the database is at
+func (s *DB) SchemaVersion() int {
+	version := 0
+	s.db.View(func(tx *bolt.Tx) error {
+		version = schemaVersion(tx)
+		return nil
+	})
+	return version
+}
+
+func schemaVersion(tx *bolt.Tx) int {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0
+	}
+	version, _ := strconv.Atoi(string(meta.Get(schemaVersionKey)))
+	return version
+}
+
+// migrate runs each pending migration in its own transaction
+func (s *DB) migrate() error {
+	for {
+		done := false
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			meta, err := tx.CreateBucketIfNotExists(metaBucket)
+			if err != nil {
+				return err
+			}
+			version := schemaVersion(tx)
+			if version > len(migrations) {
+				return fmt.Errorf("history database schema %d is newer than this version of VidFetch supports (%d)", version, len(migrations))
+			}
+			if version == len(migrations) {
+				done = true
+				return nil
+			}
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("history database migration %d failed: %w", version+1, err)
+			}
+			return meta.Put(schemaVersionKey, []byte(strconv.Itoa(version+1)))
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// Add stores a finished download, replacing any record with the same ID.
+// Like History.Add, skipped duplicates are not stored; every other download,
+// re-downloads included, gets its own record. The retention policy is
+// applied afterwards.
+func (s *DB) Add(dl downloader.Download) error {
+	if dl.Duplicate {
+		return nil
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, &dl)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.prune(&dl)
+	return err
+}
+
+// Retention returns the retention policy stored in the database
+func (s *DB) Retention() Retention {
+	var r Retention
+	s.db.View(func(tx *bolt.Tx) error {
+		r = retention(tx)
+		return nil
+	})
+	return r
+}
+
+func retention(tx *bolt.Tx) Retention {
+	var r Retention
+	if data := tx.Bucket(metaBucket).Get(retentionKey); data != nil {
+		json.Unmarshal(data, &r)
+	}
+	return r
+}
+
+// SetRetention saves a new retention policy, which applies from the next
+// Prune or Add
+func (s *DB) SetRetention(r Retention) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(retentionKey, data)
+	})
+}
+
+// Prune drops the entries the retention policy no longer keeps, appending
+// them to its archive, and returns how many were dropped
+func (s *DB) Prune() (int, error) {
+	return s.prune(nil)
+}
+
+// prune applies the retention policy. Pruned entries are archived before
+// they are deleted, and nothing is deleted if archiving fails.
+func (s *DB) prune(added *downloader.Download) (int, error) {
+	var count int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		r := retention(tx)
+		if !r.Enabled() {
+			return nil
+		}
+		pruned := prunable(tx, r, added, time.Now())
+		if err := archivePruned(r.ArchivePath, pruned); err != nil {
+			return fmt.Errorf("failed to archive pruned history: %w", err)
+		}
+		for i := range pruned {
+			if err := remove(tx, &pruned[i]); err != nil {
+				return err
+			}
+		}
+		count = len(pruned)
+		return nil
+	})
+	return count, err
+}
+
+// prunable returns the downloads r drops, newest first, with the same result
+// as Retention.apply but reading only the records it has to. With added, the
+// rest of the database is assumed to satisfy r already, so only the records
+// of the same video need checking for CollapseRetries.
+func prunable(tx *bolt.Tx, r Retention, added *downloader.Download, now time.Time) []downloader.Download {
+	drop := make(map[string]bool)
+	dates := tx.Bucket(dateIndex).Cursor()
+
+	if r.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -r.MaxAgeDays).UnixNano()
+		for k, _ := dates.First(); k != nil; k, _ = dates.Next() {
+			entry, ok := decodeTimeKey(k)
+			if !ok {
+				continue
+			}
+			if entry.at >= cutoff {
+				break
+			}
+			drop[entry.id] = true
+		}
+	}
+
+	if r.DropFailures {
+		for _, status := range []string{downloader.StatusFailed, downloader.StatusCancelled} {
+			for _, entry := range scan(tx, statusIndex, status, true) {
+				drop[entry.id] = true
+			}
+		}
+	}
+
+	if r.CollapseRetries {
+		var keys []string
+		if added != nil {
+			keys = []string{retryKey(added)}
+		} else {
+			keys = repeatedKeys(tx)
+		}
+		for _, key := range keys {
+			if key != "" {
+				collapse(tx, key, drop)
+			}
+		}
+	}
+
+	if r.MaxEntries > 0 {
+		kept := 0
+		for k, _ := dates.Last(); k != nil; k, _ = dates.Prev() {
+			entry, ok := decodeTimeKey(k)
+			if !ok || drop[entry.id] {
+				continue
+			}
+			if kept < r.MaxEntries {
+				kept++
+				continue
+			}
+			drop[entry.id] = true
+		}
+	}
+
+	pruned := make([]downloader.Download, 0, len(drop))
+	for id := range drop {
+		if dl := get(tx, []byte(id)); dl != nil {
+			pruned = append(pruned, *dl)
+		}
+	}
+	sortNewest(pruned)
+	return pruned
+}
+
+// collapse marks the records of the video or URL key that a newer attempt
+// supersedes, as Retention.apply does
+func collapse(tx *bolt.Tx, key string, drop map[string]bool) {
+	entries := scan(tx, attemptIndex, key, true)
+	newerCompleted := false
+	for i := len(entries) - 1; i >= 0; i-- {
+		dl := get(tx, []byte(entries[i].id))
+		if dl == nil {
+			continue
+		}
+		completed := dl.Status == downloader.StatusCompleted
+		if newerCompleted || (i < len(entries)-1 && !completed) {
+			drop[dl.ID] = true
+		}
+		newerCompleted = newerCompleted || completed
+	}
+}
+
+// repeatedKeys returns the keys of the attempt index with more than one
+// record, the only ones CollapseRetries can prune
+func repeatedKeys(tx *bolt.Tx) []string {
+	var keys []string
+	var last []byte
+	counted := false
+	tx.Bucket(attemptIndex).ForEach(func(k, _ []byte) error {
+		key, _, _ := bytes.Cut(k, []byte{0})
+		if bytes.Equal(key, last) {
+			if !counted {
+				keys = append(keys, string(key))
+				counted = true
+			}
+			return nil
+		}
+		last = append(last[:0], key...)
+		counted = false
+		return nil
+	})
+	return keys
+}
+
+func put(tx *bolt.Tx, dl *downloader.Download) error {
+	if old := get(tx, []byte(dl.ID)); old != nil {
+		if err := remove(tx, old); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(downloadsBucket).Put([]byte(dl.ID), data); err != nil {
+		return err
+	}
+	return forEachIndexKey(dl, func(bucket []byte, k []byte) error {
+		return tx.Bucket(bucket).Put(k, nil)
+	})
+}
+
+// remove deletes a download and its index entries
+func remove(tx *bolt.Tx, dl *downloader.Download) error {
+	if err := forEachIndexKey(dl, func(bucket []byte, k []byte) error {
+		return tx.Bucket(bucket).Delete(k)
+	}); err != nil {
+		return err
+	}
+	return tx.Bucket(downloadsBucket).Delete([]byte(dl.ID))
+}
+
+func get(tx *bolt.Tx, id []byte) *downloader.Download {
+	data := tx.Bucket(downloadsBucket).Get(id)
+	if data == nil {
+		return nil
+	}
+	var dl downloader.Download
+	if err := json.Unmarshal(data, &dl); err != nil {
+		return nil
+	}
+	return &dl
+}
+
+// forEachIndexKey calls fn with every index entry of dl
+func forEachIndexKey(dl *downloader.Download, fn func(bucket []byte, k []byte) error) error {
+	suffix := timeKey(finishedAt(dl), dl.ID)
+	if err := fn(dateIndex, suffix); err != nil {
+		return err
+	}
+	if err := fn(statusIndex, prefixed(dl.Status, suffix)); err != nil {
+		return err
+	}
+	if err := fn(platformIndex, prefixed(strings.ToLower(dl.Platform), suffix)); err != nil {
+		return err
+	}
+	for _, w := range searchWords(dl) {
+		if err := fn(wordIndex, prefixed(w, suffix)); err != nil {
+			return err
+		}
+	}
+	if k := videoKey(dl); k != nil {
+		if err := fn(videoIndex, k); err != nil {
+			return err
+		}
+	}
+	if k := attemptKey(dl); k != nil {
+		return fn(attemptIndex, k)
+	}
+	return nil
+}
+
+// videoKey is the video index entry of a completed download, nil for others
+func videoKey(dl *downloader.Download) []byte {
+	key := dl.ArchiveKey()
+	if key == "" || dl.Status != downloader.StatusCompleted {
+		return nil
+	}
+	return prefixed(key, timeKey(finishedAt(dl), dl.ID))
+}
+
+// attemptKey is the attempt index entry of a download: its video, or its
+// URL when the video is unknown, as CollapseRetries groups them
+func attemptKey(dl *downloader.Download) []byte {
+	key := retryKey(dl)
+	if key == "" {
+		return nil
+	}
+	return prefixed(key, timeKey(finishedAt(dl), dl.ID))
+}
+
+// timeKey encodes t so that keys sort chronologically, followed by id
+func timeKey(t time.Time, id string) []byte {
+	k := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return append(k, id...)
+}
+
+func prefixed(value string, suffix []byte) []byte {
+	k := make([]byte, 0, len(value)+1+len(suffix))
+	k = append(k, value...)
+	k = append(k, 0)
+	return append(k, suffix...)
+}
+
+// indexEntry is a candidate found through an index
+type indexEntry struct {
+	at int64
+	id string
+}
+
+// scan collects the entries of a valued index (status, platform, word) whose
+// value starts with prefix. With exact, the whole value must equal prefix.
+func scan(tx *bolt.Tx, bucket []byte, prefix string, exact bool) []indexEntry {
+	var list []indexEntry
+	p := []byte(prefix)
+	if exact {
+		p = append(p, 0)
+	}
+	c := tx.Bucket(bucket).Cursor()
+	for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+		// Skip the rest of the value up to the separator
+		rest := k[len(p):]
+		if !exact {
+			i := bytes.IndexByte(rest, 0)
+			if i < 0 {
+				continue
+			}
+			rest = rest[i+1:]
+		}
+		if entry, ok := decodeTimeKey(rest); ok {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// scanDates collects the entries of the date index from (inclusive) to
+// (exclusive); a zero time leaves that end open
+func scanDates(tx *bolt.Tx, from, to time.Time) []indexEntry {
+	var list []indexEntry
+	c := tx.Bucket(dateIndex).Cursor()
+	k, _ := c.First()
+	if !from.IsZero() {
+		k, _ = c.Seek(timeKey(from, ""))
+	}
+	for ; k != nil; k, _ = c.Next() {
+		entry, ok := decodeTimeKey(k)
+		if !ok {
+			continue
+		}
+		if !to.IsZero() && entry.at >= to.UnixNano() {
+			break
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+func decodeTimeKey(k []byte) (indexEntry, bool) {
+	if len(k) < 8 {
+		return indexEntry{}, false
+	}
+	return indexEntry{
+		at: int64(binary.BigEndian.Uint64(k[:8])),
+		id: string(k[8:]),
+	}, true
+}
+
+// Query finds downloads through the most selective index for q, then checks
+// the remaining conditions on each candidate and sorts the matches
+func (s *DB) Query(q Query) (Page, error) {
+	var matches []downloader.Download
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var candidates []indexEntry
+		switch {
+		case len(words(q.Text)) > 0:
+			candidates = scan(tx, wordIndex, words(q.Text)[0], false)
+		case q.Status != "":
+			candidates = scan(tx, statusIndex, q.Status, true)
+		case q.Platform != "":
+			candidates = scan(tx, platformIndex, strings.ToLower(q.Platform), true)
+		default:
+			candidates = scanDates(tx, q.From, q.To)
+		}
+
+		// Newest first; a word prefix can list a download more than once
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].at != candidates[j].at {
+				return candidates[i].at > candidates[j].at
+			}
+			return candidates[i].id > candidates[j].id
+		})
+
+		seen := make(map[string]bool, len(candidates))
+		for _, c := range candidates {
+			if seen[c.id] {
+				continue
+			}
+			seen[c.id] = true
+
+			at := time.Unix(0, c.at)
+			if (!q.From.IsZero() && at.Before(q.From)) || (!q.To.IsZero() && !at.Before(q.To)) {
+				continue
+			}
+			if dl := get(tx, []byte(c.id)); dl != nil && q.matches(dl) {
+				matches = append(matches, *dl)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	if err := q.sort(matches); err != nil {
+		return Page{}, err
+	}
+	return q.paginate(matches), nil
+}
+
+// Lookup returns the download with the given ID, or nil
+func (s *DB) Lookup(id string) *downloader.Download {
+	var dl *downloader.Download
+	s.db.View(func(tx *bolt.Tx) error {
+		dl = get(tx, []byte(id))
+		return nil
+	})
+	return dl
+}
+
+// Delete removes the download with the given ID and its index entries and
+// returns it
+func (s *DB) Delete(id string) (downloader.Download, error) {
+	var dl downloader.Download
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		old := get(tx, []byte(id))
+		if old == nil {
+			return fmt.Errorf("history entry not found: %s", id)
+		}
+		dl = *old
+		return remove(tx, old)
+	})
+	return dl, err
+}
+
+// Get returns every download, newest first
+func (s *DB) Get() []downloader.Download {
+	page, err := s.Query(Query{})
+	if err != nil {
+		return []downloader.Download{}
+	}
+	return page.Downloads
+}
+
+// Find returns the latest completed download of the video with the given
+// archive key, or nil
+func (s *DB) Find(key string) *downloader.Download {
+	var dl *downloader.Download
+	s.db.View(func(tx *bolt.Tx) error {
+		entries := scan(tx, videoIndex, key, true)
+		if len(entries) > 0 {
+			// Index order is oldest first
+			dl = get(tx, []byte(entries[len(entries)-1].id))
+		}
+		return nil
+	})
+	return dl
+}
+
+// ArchiveKeys returns the archive keys of every completed single-video download
+func (s *DB) ArchiveKeys() []string {
+	var keys []string
+	s.db.View(func(tx *bolt.Tx) error {
+		var last []byte
+		return tx.Bucket(videoIndex).ForEach(func(k, _ []byte) error {
+			key, _, ok := bytes.Cut(k, []byte{0})
+			if ok && !bytes.Equal(key, last) {
+				keys = append(keys, string(key))
+				last = append(last[:0], key...)
+			}
+			return nil
+		})
+	})
+	return keys
+}
+
+// ImportJSON copies the downloads of a history.json file into the database
+// and returns how many were imported. Records whose ID the database already
+// has are skipped, so importing the same file again adds nothing.
+func (s *DB) ImportJSON(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var legacy struct {
+		Downloads []downloader.Download `json:"downloads"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	count := 0
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		// Oldest first, as they were added
+		for i := len(legacy.Downloads) - 1; i >= 0; i-- {
+			dl := legacy.Downloads[i]
+			if dl.Duplicate || dl.ID == "" || get(tx, []byte(dl.ID)) != nil {
+				continue
+			}
+			if err := put(tx, &dl); err != nil {
+				return err
+			}
+			count++
+		}
+		return tx.Bucket(metaBucket).Put(importedKey, []byte(time.Now().Format(time.RFC3339)))
+	})
+	return count, err
+}
+
+// OpenHistoryDB opens the history database at path. If a JSON history is
+// at legacyPath, its downloads are imported and the file is renamed to
+// legacyPath + ".imported" (or ".imported.N" when that exists already).
+func OpenHistoryDB(path string, legacyPath string) (*DB, error) {
+	s, err := OpenDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(legacyPath); err != nil {
+		return s, nil
+	}
+	if _, err := s.ImportJSON(legacyPath); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := os.Rename(legacyPath, unusedPath(legacyPath+".imported")); err != nil {
+		log.Printf("Failed to rename imported %s: %v", legacyPath, err)
+	}
+	return s, nil
+}
+
+// unusedPath returns path, or path with the first free ".N" suffix, so that
+// earlier files are never overwritten
+func unusedPath(path string) string {
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s.%d", path, i)
+	}
+}
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// base is the finish time of the first test download; the others follow a
+// day apart
+var base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	s, err := OpenDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testDownload finished day days after base
+func testDownload(id string, day int, status string) downloader.Download {
+	at := base.AddDate(0, 0, day)
+	return downloader.Download{
+		ID:          id,
+		URL:         "https://example.com/watch?v=" + id,
+		Title:       "Video " + id,
+		Platform:    "Youtube",
+		VideoID:     id,
+		Status:      status,
+		CreatedAt:   at.Add(-time.Minute),
+		CompletedAt: at,
+	}
+}
+
+func addAll(t *testing.T, s *DB, downloads ...downloader.Download) {
+	t.Helper()
+	for _, dl := range downloads {
+		if err := s.Add(dl); err != nil {
+			t.Fatalf("Add(%s): %v", dl.ID, err)
+		}
+	}
+}
+
+func ids(downloads []downloader.Download) []string {
+	list := make([]string, len(downloads))
+	for i, dl := range downloads {
+		list[i] = dl.ID
+	}
+	return list
+}
+
+func TestQueryDateWindow(t *testing.T) {
+	s := openTestDB(t)
+	for day := range 10 {
+		addAll(t, s, testDownload(fmt.Sprintf("d%d", day), day, downloader.StatusCompleted))
+	}
+
+	for _, tc := range []struct {
+		name      string
+		q         Query
+		want      []string
+		wantTotal int
+	}{
+		{"window", Query{From: base.AddDate(0, 0, 3), To: base.AddDate(0, 0, 7)}, []string{"d6", "d5", "d4", "d3"}, 4},
+		{"from only", Query{From: base.AddDate(0, 0, 8)}, []string{"d9", "d8"}, 2},
+		{"to only", Query{To: base.AddDate(0, 0, 2)}, []string{"d1", "d0"}, 2},
+		{"to is exclusive", Query{From: base, To: base}, []string{}, 0},
+		{"oldest first, paged", Query{From: base.AddDate(0, 0, 2), To: base.AddDate(0, 0, 9), Sort: SortOldest, Offset: 2, Limit: 3}, []string{"d4", "d5", "d6"}, 7},
+		{"last page", Query{From: base.AddDate(0, 0, 2), Offset: 6, Limit: 5}, []string{"d3", "d2"}, 8},
+		{"past the end", Query{From: base.AddDate(0, 0, 2), Offset: 8}, []string{}, 8},
+		{"with status", Query{Status: downloader.StatusCompleted, From: base.AddDate(0, 0, 5), To: base.AddDate(0, 0, 6)}, []string{"d5"}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := s.Query(tc.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(page.Downloads); !slices.Equal(got, tc.want) || page.Total != tc.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", got, page.Total, tc.want, tc.wantTotal)
+			}
+		})
+	}
+}
+
+// checkIndexes fails when an index entry points to a missing record, or a
+// record lacks one of its index entries
+func checkIndexes(t *testing.T, s *DB) {
+	t.Helper()
+	s.db.View(func(tx *bolt.Tx) error {
+		downloads := tx.Bucket(downloadsBucket)
+		for _, bucket := range [][]byte{dateIndex, statusIndex, platformIndex, wordIndex, videoIndex, attemptIndex} {
+			tx.Bucket(bucket).ForEach(func(k, _ []byte) error {
+				suffix := k
+				if !bytes.Equal(bucket, dateIndex) {
+					// Indexed values never contain the separator
+					_, suffix, _ = bytes.Cut(k, []byte{0})
+				}
+				entry, ok := decodeTimeKey(suffix)
+				if !ok || downloads.Get([]byte(entry.id)) == nil {
+					t.Errorf("dangling %s entry %q", bucket, k)
+				}
+				return nil
+			})
+		}
+		return downloads.ForEach(func(id, _ []byte) error {
+			return forEachIndexKey(get(tx, id), func(bucket []byte, k []byte) error {
+				if tx.Bucket(bucket).Get(k) == nil {
+					t.Errorf("%s has no %s entry %q", id, bucket, k)
+				}
+				return nil
+			})
+		})
+	})
+}
+
+func TestDeleteAndPruneKeepIndexes(t *testing.T) {
+	s := openTestDB(t)
+	retry := testDownload("r2", 4, downloader.StatusCompleted)
+	retry.VideoID = "r1"
+	addAll(t, s,
+		testDownload("a", 0, downloader.StatusCompleted),
+		testDownload("b", 1, downloader.StatusFailed),
+		testDownload("r1", 2, downloader.StatusFailed),
+		testDownload("c", 3, downloader.StatusCancelled),
+		retry,
+		testDownload("d", 5, downloader.StatusCompleted),
+	)
+	checkIndexes(t, s)
+
+	if _, err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Lookup("a") != nil || s.Find(downloader.ArchiveKey("Youtube", "a")) != nil {
+		t.Error("a is still found after Delete")
+	}
+	checkIndexes(t, s)
+
+	if err := s.SetRetention(Retention{DropFailures: true, CollapseRetries: true}); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := s.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(s.Get()); pruned != 3 || !slices.Equal(got, []string{"d", "r2"}) {
+		t.Errorf("pruned %d, kept %v; want 3 pruned, [d r2] kept", pruned, got)
+	}
+	checkIndexes(t, s)
+
+	// Through Add, which only looks at the new record's video
+	if err := s.SetRetention(Retention{MaxEntries: 2, CollapseRetries: true}); err != nil {
+		t.Fatal(err)
+	}
+	again := testDownload("d2", 6, downloader.StatusCompleted)
+	again.VideoID = "d"
+	addAll(t, s, again, testDownload("e", 7, downloader.StatusCompleted))
+	if got := ids(s.Get()); !slices.Equal(got, []string{"e", "d2"}) {
+		t.Errorf("kept %v, want [e d2]", got)
+	}
+	if dl := s.Find(downloader.ArchiveKey("Youtube", "r1")); dl != nil {
+		t.Errorf("Find(r1) = %s after it was pruned", dl.ID)
+	}
+	checkIndexes(t, s)
+}
+
+func TestPruneArchivesBeforeDeleting(t *testing.T) {
+	s := openTestDB(t)
+	addAll(t, s,
+		testDownload("a", 0, downloader.StatusCompleted),
+		testDownload("b", 1, downloader.StatusCompleted),
+		testDownload("c", 2, downloader.StatusCompleted),
+	)
+
+	// The archive cannot be written: nothing may be deleted
+	blocked := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocked, nil, 0644)
+	s.SetRetention(Retention{MaxEntries: 1, ArchivePath: filepath.Join(blocked, "pruned.jsonl.gz")})
+	if _, err := s.Prune(); err == nil {
+		t.Error("Prune succeeded without its archive")
+	}
+	if got := len(s.Get()); got != 3 {
+		t.Fatalf("%d entries left after a failed archive, want 3", got)
+	}
+
+	archive := filepath.Join(t.TempDir(), "pruned.jsonl.gz")
+	s.SetRetention(Retention{MaxEntries: 1, ArchivePath: archive})
+	pruned, err := s.Prune()
+	if err != nil || pruned != 2 {
+		t.Fatalf("Prune() = %d, %v; want 2", pruned, err)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archived []string
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var entry PrunedEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		archived = append(archived, entry.ID)
+	}
+	if !slices.Equal(archived, []string{"b", "a"}) {
+		t.Errorf("archived %v, want [b a]", archived)
+	}
+}
+
+func TestImportJSONByID(t *testing.T) {
+	s := openTestDB(t)
+	path := filepath.Join(t.TempDir(), "history.json")
+	write := func(downloads ...downloader.Download) {
+		t.Helper()
+		data, _ := json.Marshal(map[string]any{"downloads": downloads})
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dup := testDownload("dup", 3, downloader.StatusSkipped)
+	dup.Duplicate = true
+
+	write(testDownload("b", 1, downloader.StatusCompleted), testDownload("a", 0, downloader.StatusFailed), dup)
+	for i, want := range []int{2, 0} {
+		if n, err := s.ImportJSON(path); err != nil || n != want {
+			t.Errorf("import %d: %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+
+	// A later file adds only what the database does not have
+	changed := testDownload("a", 0, downloader.StatusCompleted)
+	write(testDownload("c", 2, downloader.StatusCompleted), testDownload("b", 1, downloader.StatusCompleted), changed)
+	if n, err := s.ImportJSON(path); err != nil || n != 1 {
+		t.Errorf("import 3: %d, %v; want 1", n, err)
+	}
+	if got := ids(s.Get()); !slices.Equal(got, []string{"c", "b", "a"}) {
+		t.Errorf("history %v, want [c b a]", got)
+	}
+	if dl := s.Lookup("a"); dl == nil || dl.Status != downloader.StatusFailed {
+		t.Error("import replaced an existing record")
+	}
+	checkIndexes(t, s)
+}
+
+func TestOpenHistoryDBKeepsImportedFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "history.json")
+	for i := range 2 {
+		data, _ := json.Marshal(map[string]any{"downloads": []downloader.Download{testDownload(fmt.Sprint(i), i, downloader.StatusCompleted)}})
+		os.WriteFile(legacy, data, 0644)
+		s, err := OpenHistoryDB(filepath.Join(dir, "history.db"), legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+	}
+	for _, name := range []string{"history.json.imported", "history.json.imported.1"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("%s is still there", legacy)
+	}
+}
+
+func TestMigrateEmptyDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	for range 2 { // Creating, then reopening at the current schema
+		s, err := OpenDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.db.View(func(tx *bolt.Tx) error {
+			if v := schemaVersion(tx); v != len(migrations) {
+				t.Errorf("schema %d, want %d", v, len(migrations))
+			}
+			return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				if !bytes.Equal(name, metaBucket) && !bytes.Equal(name, downloadsBucket) && !strings.HasPrefix(string(name), "idx_") {
+					t.Errorf("unexpected bucket %s", name)
+				}
+				return nil
+			})
+		})
+		for _, bucket := range [][]byte{metaBucket, downloadsBucket, dateIndex, statusIndex, platformIndex, wordIndex, videoIndex, attemptIndex} {
+			s.db.View(func(tx *bolt.Tx) error {
+				if tx.Bucket(bucket) == nil {
+					t.Errorf("missing bucket %s", bucket)
+				}
+				return nil
+			})
+		}
+		if page, err := s.Query(Query{}); err != nil || page.Total != 0 {
+			t.Errorf("Query() = %d, %v on an empty database", page.Total, err)
+		}
+		s.Close()
+	}
+
+	// A newer schema is refused rather than misread
+	s, _ := OpenDB(path)
+	s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(schemaVersionKey, []byte(fmt.Sprint(len(migrations)+1)))
+	})
+	s.Close()
+	if s, err := OpenDB(path); err == nil {
+		s.Close()
+		t.Error("OpenDB accepted a newer schema")
+	}
+}
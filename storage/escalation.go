@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Escalation persists the anti-blocking settings the downloader learns and
+// uses: the escalation level that worked per site and the proxy list
+type Escalation struct {
+	Levels   map[string]int `json:"levels"`
+	Proxies  []string       `json:"proxies"`
+	LastSync time.Time      `json:"last_sync"`
+	path     string
+	mu       sync.RWMutex
+}
+
+// NewEscalation loads the file at path. On error the returned Escalation is still
+// usable, starting empty.
+func NewEscalation(path string) (*Escalation, error) {
+	e := &Escalation{
+		Levels: make(map[string]int),
+		path:   path,
+	}
+
+	// Ensure dir exists
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+
+	if err := e.Load(); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return e, nil
+		}
+		return e, err
+	}
+	return e, nil
+}
+
+func (e *Escalation) Load() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := loadJSON(e.path, e); err != nil {
+		return err
+	}
+	if e.Levels == nil {
+		e.Levels = make(map[string]int)
+	}
+	return nil
+}
+
+func (e *Escalation) Save() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.LastSync = time.Now()
+	return saveJSON(e.path, e)
+}
+
+// SetLevel records the escalation level that worked for a site
+func (e *Escalation) SetLevel(site string, level int) error {
+	e.mu.Lock()
+	e.Levels[site] = level
+	e.mu.Unlock()
+	return e.Save()
+}
+
+// GetLevels returns a copy of the per-site levels
+func (e *Escalation) GetLevels() map[string]int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	levels := make(map[string]int, len(e.Levels))
+	for site, level := range e.Levels {
+		levels[site] = level
+	}
+	return levels
+}
+
+// SetProxies replaces the proxy list
+func (e *Escalation) SetProxies(proxies []string) error {
+	e.mu.Lock()
+	e.Proxies = append([]string(nil), proxies...)
+	e.mu.Unlock()
+	return e.Save()
+}
+
+// GetProxies returns a copy of the proxy list
+func (e *Escalation) GetProxies() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]string(nil), e.Proxies...)
+}
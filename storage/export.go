@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// Export formats
+const (
+	FormatJSONL = "jsonl" // One download per line, the full record
+	FormatCSV   = "csv"   // Flattened, nested fields as "options.format"
+)
+
+// Import deduplication, see Merge
+const (
+	MergeByID    = "id"    // Skip records whose download ID is already in the history
+	MergeByVideo = "video" // Also skip completed downloads of videos already downloaded
+)
+
+// DefaultColumns are the CSV columns exported when none are chosen
+var DefaultColumns = []string{"id", "completed_at", "status", "platform", "title", "url", "video_id", "file_path", "file_size", "duration", "error"}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// column is a flattened field of Download
+type column struct {
+	name string
+	kind reflect.Kind // reflect.String for times
+}
+
+// columns lists the flattened fields of Download in declaration order
+var columns = flattenType(reflect.TypeOf(downloader.Download{}), "")
+
+func flattenType(t reflect.Type, prefix string) []column {
+	var list []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		name = prefix + name
+		switch {
+		case f.Type == timeType:
+			list = append(list, column{name, reflect.String})
+		case f.Type.Kind() == reflect.Struct:
+			list = append(list, flattenType(f.Type, name+".")...)
+		default:
+			list = append(list, column{name, f.Type.Kind()})
+		}
+	}
+	return list
+}
+
+// Columns returns every column a CSV export can include
+func Columns() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// FormatForPath picks the export format from a file name: CSV for .csv,
+// JSON Lines otherwise
+func FormatForPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return FormatCSV
+	}
+	return FormatJSONL
+}
+
+// Export writes downloads to w. CSV has a header row and the given columns
+// (DefaultColumns when empty); lists are written as JSON arrays. JSON Lines
+// holds full records, so columns only apply to CSV.
+func Export(w io.Writer, downloads []downloader.Download, format string, cols []string) error {
+	switch format {
+	case FormatJSONL:
+		buf := bufio.NewWriter(w)
+		enc := json.NewEncoder(buf)
+		for _, dl := range downloads {
+			if err := enc.Encode(dl); err != nil {
+				return err
+			}
+		}
+		return buf.Flush()
+	case FormatCSV:
+		if len(cols) == 0 {
+			cols = DefaultColumns
+		}
+		known := make(map[string]bool, len(columns))
+		for _, c := range columns {
+			known[c.name] = true
+		}
+		for _, name := range cols {
+			if !known[name] {
+				return fmt.Errorf("unknown column: %s", name)
+			}
+		}
+
+		cw := csv.NewWriter(w)
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+		for _, dl := range downloads {
+			fields, err := flatten(dl)
+			if err != nil {
+				return err
+			}
+			row := make([]string, len(cols))
+			for i, name := range cols {
+				row[i] = fields[name]
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown export format: %s", format)
+}
+
+// flatten turns a download into column -> text
+func flatten(dl downloader.Download) (map[string]string, error) {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				walk(prefix+k+".", child)
+			}
+		case nil:
+			fields[strings.TrimSuffix(prefix, ".")] = ""
+		case string:
+			fields[strings.TrimSuffix(prefix, ".")] = v
+		case json.Number:
+			fields[strings.TrimSuffix(prefix, ".")] = v.String()
+		case bool:
+			fields[strings.TrimSuffix(prefix, ".")] = strconv.FormatBool(v)
+		default:
+			text, _ := json.Marshal(v)
+			fields[strings.TrimSuffix(prefix, ".")] = string(text)
+		}
+	}
+	walk("", tree)
+	return fields, nil
+}
+
+// ReadExport reads downloads written by Export. CSV files may have any
+// subset of the columns, in any order; missing fields stay empty.
+func ReadExport(r io.Reader, format string) ([]downloader.Download, error) {
+	switch format {
+	case FormatJSONL:
+		var list []downloader.Download
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var dl downloader.Download
+			if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			list = append(list, dl)
+		}
+		return list, scanner.Err()
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		var list []downloader.Download
+		for line := 2; ; line++ {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return list, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			dl, err := unflatten(header, row)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			list = append(list, dl)
+		}
+	}
+	return nil, fmt.Errorf("unknown export format: %s", format)
+}
+
+// unflatten rebuilds a download from CSV cells. Unknown columns are ignored.
+func unflatten(header []string, row []string) (downloader.Download, error) {
+	kinds := make(map[string]reflect.Kind, len(columns))
+	for _, c := range columns {
+		kinds[c.name] = c.kind
+	}
+
+	tree := make(map[string]any)
+	for i, name := range header {
+		kind, ok := kinds[name]
+		if !ok || i >= len(row) || row[i] == "" {
+			continue
+		}
+		cell := row[i]
+
+		var value any
+		switch kind {
+		case reflect.String:
+			value = cell
+		case reflect.Bool:
+			b, err := strconv.ParseBool(cell)
+			if err != nil {
+				return downloader.Download{}, fmt.Errorf("%s: %w", name, err)
+			}
+			value = b
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			value = json.Number(cell)
+		default:
+			value = json.RawMessage(cell)
+		}
+
+		// Nest "options.format" back under "options"
+		node := tree
+		parts := strings.Split(name, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+
+	var dl downloader.Download
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return dl, err
+	}
+	err = json.Unmarshal(data, &dl)
+	return dl, err
+}
+
+// Merge adds imported downloads to hist and returns how many were added.
+// Records without an ID, or whose ID is already in the history, are skipped.
+// With MergeByVideo, completed downloads of videos the history already has
+// are skipped too.
+func Merge(hist HistoryStore, downloads []downloader.Download, by string) (int, error) {
+	if by != MergeByID && by != MergeByVideo {
+		return 0, fmt.Errorf("unknown merge mode: %s", by)
+	}
+
+	added := 0
+	for _, dl := range downloads {
+		if dl.ID == "" || dl.Duplicate || hist.Lookup(dl.ID) != nil {
+			continue
+		}
+		if key := dl.ArchiveKey(); key != "" && dl.Status == downloader.StatusCompleted && by == MergeByVideo {
+			if hist.Find(key) != nil {
+				continue
+			}
+		}
+		if err := hist.Add(dl); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
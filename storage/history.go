@@ -1,22 +1,28 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/shubhambadola/VidFetch/downloader"
 )
 
+// History keeps finished downloads in a JSON file
 type History struct {
 	Downloads []downloader.Download `json:"downloads"`
+	Policy    Retention             `json:"retention"`
 	LastSync  time.Time             `json:"last_sync"`
 	path      string
 	mu        sync.RWMutex
 }
 
+// NewHistory loads the file at path. On error the returned History is still
+// usable, starting empty. With an empty path it lives in memory only.
 func NewHistory(path string) (*History, error) {
 	h := &History{
 		Downloads: []downloader.Download{},
@@ -28,10 +34,10 @@ func NewHistory(path string) (*History, error) {
 	os.MkdirAll(dir, 0755)
 
 	if err := h.Load(); err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, os.ErrNotExist) {
 			return h, nil
 		}
-		return nil, err
+		return h, err
 	}
 	return h, nil
 }
@@ -40,11 +46,10 @@ func (h *History) Load() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	data, err := os.ReadFile(h.path)
-	if err != nil {
-		return err
+	if h.path == "" {
+		return nil
 	}
-	return json.Unmarshal(data, h)
+	return loadJSON(h.path, h)
 }
 
 func (h *History) Save() error {
@@ -52,21 +57,104 @@ func (h *History) Save() error {
 	defer h.mu.Unlock()
 
 	h.LastSync = time.Now()
-	data, err := json.MarshalIndent(h, "", "  ")
-	if err != nil {
-		return err
+	if h.path == "" {
+		return nil
 	}
-	return os.WriteFile(h.path, data, 0644)
+	return saveJSON(h.path, h)
 }
 
+// Add prepends a finished download. Duplicates skipped or linked by the
+// archive are not recorded again; re-downloads of a video are kept as
+// records of their own. The retention policy is applied afterwards.
 func (h *History) Add(dl downloader.Download) error {
+	if dl.Duplicate {
+		return nil
+	}
+
 	h.mu.Lock()
 	// Prepend
 	h.Downloads = append([]downloader.Download{dl}, h.Downloads...)
+	_, pruneErr := h.prune()
+	h.mu.Unlock()
+	if err := h.Save(); err != nil {
+		return err
+	}
+	return pruneErr
+}
+
+// Retention returns the retention policy
+func (h *History) Retention() Retention {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Policy
+}
+
+// SetRetention saves a new retention policy, which applies from the next
+// Prune or Add
+func (h *History) SetRetention(r Retention) error {
+	h.mu.Lock()
+	h.Policy = r
 	h.mu.Unlock()
 	return h.Save()
 }
 
+// Prune drops the entries the retention policy no longer keeps, appending
+// them to its archive, and returns how many were dropped
+func (h *History) Prune() (int, error) {
+	h.mu.Lock()
+	count, err := h.prune()
+	h.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return count, h.Save()
+}
+
+// prune applies the retention policy and returns how many entries it
+// dropped. They are archived first and kept if that fails. The caller holds
+// h.mu and saves.
+func (h *History) prune() (int, error) {
+	if !h.Policy.Enabled() {
+		return 0, nil
+	}
+	sortNewest(h.Downloads)
+	keep, pruned := h.Policy.apply(h.Downloads, time.Now())
+	if err := archivePruned(h.Policy.ArchivePath, pruned); err != nil {
+		return 0, fmt.Errorf("failed to archive pruned history: %w", err)
+	}
+	if keep == nil {
+		keep = []downloader.Download{}
+	}
+	h.Downloads = keep
+	return len(pruned), nil
+}
+
+// Find returns the latest completed download of the video with the given
+// archive key, or nil
+func (h *History) Find(key string) *downloader.Download {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, dl := range h.Downloads {
+		if dl.Status == downloader.StatusCompleted && dl.ArchiveKey() == key {
+			return &dl
+		}
+	}
+	return nil
+}
+
+// ArchiveKeys returns the archive keys of every completed single-video download
+func (h *History) ArchiveKeys() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var keys []string
+	for _, dl := range h.Downloads {
+		if key := dl.ArchiveKey(); key != "" && dl.Status == downloader.StatusCompleted && !dl.IsCollection() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 func (h *History) Get() []downloader.Download {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -75,3 +163,50 @@ func (h *History) Get() []downloader.Download {
 	copy(dst, h.Downloads)
 	return dst
 }
+
+// Query filters and sorts the history in memory
+func (h *History) Query(q Query) (Page, error) {
+	var matches []downloader.Download
+	for _, dl := range h.Get() {
+		if q.matches(&dl) {
+			matches = append(matches, dl)
+		}
+	}
+	if err := q.sort(matches); err != nil {
+		return Page{}, err
+	}
+	return q.paginate(matches), nil
+}
+
+// Lookup returns the download with the given ID, or nil
+func (h *History) Lookup(id string) *downloader.Download {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, dl := range h.Downloads {
+		if dl.ID == id {
+			return &dl
+		}
+	}
+	return nil
+}
+
+// Delete removes the download with the given ID and returns it
+func (h *History) Delete(id string) (downloader.Download, error) {
+	h.mu.Lock()
+	i := slices.IndexFunc(h.Downloads, func(dl downloader.Download) bool {
+		return dl.ID == id
+	})
+	if i < 0 {
+		h.mu.Unlock()
+		return downloader.Download{}, fmt.Errorf("history entry not found: %s", id)
+	}
+	dl := h.Downloads[i]
+	h.Downloads = slices.Delete(h.Downloads, i, i+1)
+	h.mu.Unlock()
+	return dl, h.Save()
+}
+
+// Close is a no-op; every change is saved immediately
+func (h *History) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// Queue persists unfinished downloads so they survive an app restart
+type Queue struct {
+	Downloads map[string]downloader.Download `json:"downloads"`
+	LastSync  time.Time                      `json:"last_sync"`
+	path      string
+	mu        sync.RWMutex
+}
+
+// NewQueue loads the file at path. On error the returned Queue is still
+// usable, starting empty.
+func NewQueue(path string) (*Queue, error) {
+	q := &Queue{
+		Downloads: make(map[string]downloader.Download),
+		path:      path,
+	}
+
+	// Ensure dir exists
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+
+	if err := q.Load(); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return q, nil
+		}
+		return q, err
+	}
+	return q, nil
+}
+
+func (q *Queue) Load() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := loadJSON(q.path, q); err != nil {
+		return err
+	}
+	if q.Downloads == nil {
+		q.Downloads = make(map[string]downloader.Download)
+	}
+	return nil
+}
+
+func (q *Queue) Save() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.LastSync = time.Now()
+	return saveJSON(q.path, q)
+}
+
+// Update records the latest state of a download, dropping it once finished
+// (finished downloads belong to History)
+func (q *Queue) Update(dl downloader.Download) error {
+	q.mu.Lock()
+	if dl.Finished() {
+		delete(q.Downloads, dl.ID)
+	} else {
+		q.Downloads[dl.ID] = dl
+	}
+	q.mu.Unlock()
+	return q.Save()
+}
+
+// Get returns the persisted downloads, oldest first
+func (q *Queue) Get() []downloader.Download {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	list := make([]downloader.Download, 0, len(q.Downloads))
+	for _, dl := range q.Downloads {
+		list = append(list, dl)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
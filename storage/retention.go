@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// Retention limits what the history keeps. The zero value keeps everything.
+type Retention struct {
+	MaxEntries      int    `json:"max_entries"`      // Newest entries kept, 0 for no limit
+	MaxAgeDays      int    `json:"max_age_days"`     // Entries finished longer ago are dropped, 0 for no limit
+	DropFailures    bool   `json:"drop_failures"`    // Drop failed and cancelled downloads
+	CollapseRetries bool   `json:"collapse_retries"` // Keep only the latest record of each video or URL
+	ArchivePath     string `json:"archive_path"`     // Pruned entries are appended here (gzipped JSON lines); empty to discard them
+}
+
+// Enabled reports whether the policy prunes anything
+func (r Retention) Enabled() bool {
+	return r.MaxEntries > 0 || r.MaxAgeDays > 0 || r.DropFailures || r.CollapseRetries
+}
+
+// apply splits downloads, sorted newest first, into the ones the policy keeps
+// and the ones it prunes
+func (r Retention) apply(downloads []downloader.Download, now time.Time) (keep []downloader.Download, pruned []downloader.Download) {
+	newer := make(map[string]bool)          // Videos with a newer record
+	newerCompleted := make(map[string]bool) // Videos with a newer completed record
+	for _, dl := range downloads {
+		failure := dl.Status == downloader.StatusFailed || dl.Status == downloader.StatusCancelled
+		drop := r.DropFailures && failure
+		if r.MaxAgeDays > 0 && finishedAt(&dl).Before(now.AddDate(0, 0, -r.MaxAgeDays)) {
+			drop = true
+		}
+		if r.CollapseRetries {
+			key := retryKey(&dl)
+			// A retry supersedes earlier attempts, but only a newer success
+			// supersedes a success
+			if newerCompleted[key] || (newer[key] && dl.Status != downloader.StatusCompleted) {
+				drop = true
+			}
+			newer[key] = true
+			if dl.Status == downloader.StatusCompleted {
+				newerCompleted[key] = true
+			}
+		}
+		if !drop && r.MaxEntries > 0 && len(keep) >= r.MaxEntries {
+			drop = true
+		}
+
+		if drop {
+			pruned = append(pruned, dl)
+		} else {
+			keep = append(keep, dl)
+		}
+	}
+	return keep, pruned
+}
+
+// retryKey identifies the attempts CollapseRetries collapses: the video, or
+// the URL when the video is unknown
+func retryKey(dl *downloader.Download) string {
+	if key := dl.ArchiveKey(); key != "" {
+		return key
+	}
+	return dl.URL
+}
+
+// sortNewest sorts downloads newest first, the order Retention.apply expects
+func sortNewest(downloads []downloader.Download) {
+	sort.SliceStable(downloads, func(i, j int) bool {
+		return finishedAt(&downloads[i]).After(finishedAt(&downloads[j]))
+	})
+}
+
+// PrunedEntry is the compact record of a pruned download kept in the
+// retention archive
+type PrunedEntry struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	Platform    string    `json:"platform,omitempty"`
+	VideoID     string    `json:"video_id,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	FilePath    string    `json:"file_path,omitempty"`
+	FileSize    int64     `json:"file_size,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	PrunedAt    time.Time `json:"pruned_at"`
+}
+
+// archivePruned appends the pruned downloads to the retention archive at
+// path as one gzip member of JSON lines. Readers decompress the whole file as
+// a single stream.
+func archivePruned(path string, pruned []downloader.Download) error {
+	if path == "" || len(pruned) == 0 {
+		return nil
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := bufio.NewWriter(f)
+	zw := gzip.NewWriter(buf)
+	enc := json.NewEncoder(zw)
+	now := time.Now()
+	for _, dl := range pruned {
+		err := enc.Encode(PrunedEntry{
+			ID:          dl.ID,
+			URL:         dl.URL,
+			Title:       dl.Title,
+			Platform:    dl.Platform,
+			VideoID:     dl.VideoID,
+			Status:      dl.Status,
+			Error:       dl.Error,
+			FilePath:    dl.FilePath,
+			FileSize:    dl.FileSize,
+			CreatedAt:   dl.CreatedAt,
+			CompletedAt: dl.CompletedAt,
+			PrunedAt:    now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
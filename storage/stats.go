@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// Bucket aggregates the downloads of one day, week or platform
+type Bucket struct {
+	Key         string  `json:"key"` // "2006-01-02", "2006-W01" or the platform
+	Downloads   int     `json:"downloads"`
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"`
+	Cancelled   int     `json:"cancelled"`
+	Bytes       int64   `json:"bytes"`        // Size of the completed downloads
+	AvgSize     int64   `json:"avg_size"`     // Per completed download
+	SuccessRate float64 `json:"success_rate"` // Completed out of completed and failed, 0-1
+	Throughput  float64 `json:"throughput"`   // Mean bytes per second of the completed downloads
+
+	timedBytes int64   // Size of the completed downloads with a known duration
+	seconds    float64 // Time those took
+}
+
+// ErrorCount is how often downloads failed for one reason
+type ErrorCount struct {
+	Kind    string `json:"kind"` // downloader.ErrorKind
+	Count   int    `json:"count"`
+	Example string `json:"example"` // Message of the latest such failure
+}
+
+// Stats summarizes the download history
+type Stats struct {
+	Total     Bucket                `json:"total"`
+	Days      []Bucket              `json:"days"`      // Newest first
+	Weeks     []Bucket              `json:"weeks"`     // ISO weeks, newest first
+	Platforms []Bucket              `json:"platforms"` // Most downloads first
+	Largest   []downloader.Download `json:"largest"`   // Largest completed downloads
+	Errors    []ErrorCount          `json:"errors"`    // Most common first
+}
+
+// ComputeStats aggregates finished downloads by day, week and platform, in
+// local time, listing up to largest of the biggest files. Playlists and
+// channels are counted through their entries.
+// Throughput divides the size of completed downloads by the time yt-dlp
+// spent on them (Download.TransferTime); records from before that was kept
+// are left out of it.
+func ComputeStats(downloads []downloader.Download, largest int) Stats {
+	stats := Stats{Total: Bucket{Key: "total"}}
+	days := make(map[string]*Bucket)
+	weeks := make(map[string]*Bucket)
+	platforms := make(map[string]*Bucket)
+	errs := make(map[string]*ErrorCount)
+	completed := []downloader.Download{}
+
+	bucket := func(m map[string]*Bucket, key string) *Bucket {
+		b, ok := m[key]
+		if !ok {
+			b = &Bucket{Key: key}
+			m[key] = b
+		}
+		return b
+	}
+
+	// Newest first, so the first error of each kind is the latest
+	list := append([]downloader.Download(nil), downloads...)
+	sortNewest(list)
+	for _, dl := range list {
+		if dl.IsCollection() {
+			continue
+		}
+		at := finishedAt(&dl).Local()
+		year, week := at.ISOWeek()
+		platform := strings.ToLower(dl.Platform)
+		if platform == "" {
+			platform = "unknown"
+		}
+		for _, b := range []*Bucket{
+			&stats.Total,
+			bucket(days, at.Format("2006-01-02")),
+			bucket(weeks, isoWeekKey(year, week)),
+			bucket(platforms, platform),
+		} {
+			b.add(&dl)
+		}
+
+		switch dl.Status {
+		case downloader.StatusCompleted:
+			completed = append(completed, dl)
+		case downloader.StatusFailed:
+			kind := dl.ErrorKind
+			if kind == "" {
+				kind = string(downloader.ErrUnknown)
+			}
+			e, ok := errs[kind]
+			if !ok {
+				e = &ErrorCount{Kind: kind, Example: dl.Error}
+				errs[kind] = e
+			}
+			e.Count++
+		}
+	}
+
+	stats.Total.finish()
+	stats.Days = sortedBuckets(days, func(a, b *Bucket) bool { return a.Key > b.Key })
+	stats.Weeks = sortedBuckets(weeks, func(a, b *Bucket) bool { return a.Key > b.Key })
+	stats.Platforms = sortedBuckets(platforms, func(a, b *Bucket) bool {
+		if a.Downloads != b.Downloads {
+			return a.Downloads > b.Downloads
+		}
+		return a.Key < b.Key
+	})
+
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].FileSize > completed[j].FileSize
+	})
+	stats.Largest = completed[:min(max(largest, 0), len(completed))]
+
+	stats.Errors = []ErrorCount{}
+	for _, e := range errs {
+		stats.Errors = append(stats.Errors, *e)
+	}
+	sort.Slice(stats.Errors, func(i, j int) bool {
+		if stats.Errors[i].Count != stats.Errors[j].Count {
+			return stats.Errors[i].Count > stats.Errors[j].Count
+		}
+		return stats.Errors[i].Kind < stats.Errors[j].Kind
+	})
+	return stats
+}
+
+func (b *Bucket) add(dl *downloader.Download) {
+	b.Downloads++
+	switch dl.Status {
+	case downloader.StatusCompleted:
+		b.Completed++
+		b.Bytes += dl.FileSize
+		if dl.TransferTime > 0 && dl.FileSize > 0 {
+			b.timedBytes += dl.FileSize
+			b.seconds += dl.TransferTime
+		}
+	case downloader.StatusFailed:
+		b.Failed++
+	case downloader.StatusCancelled:
+		b.Cancelled++
+	}
+}
+
+// finish computes the averages once every download is added
+func (b *Bucket) finish() {
+	if b.Completed > 0 {
+		b.AvgSize = b.Bytes / int64(b.Completed)
+	}
+	if b.Completed+b.Failed > 0 {
+		b.SuccessRate = float64(b.Completed) / float64(b.Completed+b.Failed)
+	}
+	if b.seconds > 0 {
+		b.Throughput = float64(b.timedBytes) / b.seconds
+	}
+}
+
+func sortedBuckets(m map[string]*Bucket, less func(a, b *Bucket) bool) []Bucket {
+	list := make([]Bucket, 0, len(m))
+	for _, b := range m {
+		b.finish()
+		list = append(list, *b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return less(&list[i], &list[j])
+	})
+	return list
+}
+
+func isoWeekKey(year int, week int) string {
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// HistoryStore keeps finished downloads. History is the original JSON file
+// implementation, DB the indexed embedded database.
+type HistoryStore interface {
+	Add(dl downloader.Download) error
+	Get() []downloader.Download
+	Find(key string) *downloader.Download
+	Lookup(id string) *downloader.Download
+	ArchiveKeys() []string
+	Query(q Query) (Page, error)
+	Delete(id string) (downloader.Download, error)
+	Retention() Retention
+	SetRetention(r Retention) error
+	Prune() (int, error)
+	Close() error
+}
+
+// Query selects finished downloads. Zero fields match everything.
+type Query struct {
+	Status   string    `json:"status"`
+	Platform string    `json:"platform"` // Case-insensitive, e.g. "youtube"
+	From     time.Time `json:"from"`     // Finished at or after
+	To       time.Time `json:"to"`       // Finished before
+	Text     string    `json:"text"`     // Words (or word prefixes) of the title, URL or file name
+	Contains string    `json:"contains"` // Case-insensitive substring of the title or URL
+	Sort     string    `json:"sort"`     // See Sort*, newest first by default
+	Offset   int       `json:"offset"`
+	Limit    int       `json:"limit"` // 0 for no limit
+}
+
+// Query sort orders
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+	SortTitle  = "title"
+	SortSize   = "size" // Largest first
+)
+
+// Page is one page of query results
+type Page struct {
+	Downloads []downloader.Download `json:"downloads"`
+	Total     int                   `json:"total"` // Matches across all pages
+}
+
+// finishedAt is when a download ended, the time it is indexed and sorted by
+func finishedAt(dl *downloader.Download) time.Time {
+	if !dl.CompletedAt.IsZero() {
+		return dl.CompletedAt
+	}
+	return dl.CreatedAt
+}
+
+// words splits text into lower-case words for text search
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchWords returns the distinct words a download can be found by
+func searchWords(dl *downloader.Download) []string {
+	seen := make(map[string]bool)
+	var list []string
+	for _, text := range []string{dl.Title, dl.URL, dl.FilePath} {
+		for _, w := range words(text) {
+			if !seen[w] {
+				seen[w] = true
+				list = append(list, w)
+			}
+		}
+	}
+	return list
+}
+
+// matches reports whether dl satisfies every condition of q
+func (q Query) matches(dl *downloader.Download) bool {
+	if q.Status != "" && dl.Status != q.Status {
+		return false
+	}
+	if q.Platform != "" && !strings.EqualFold(dl.Platform, q.Platform) {
+		return false
+	}
+	at := finishedAt(dl)
+	if !q.From.IsZero() && at.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !at.Before(q.To) {
+		return false
+	}
+	if q.Contains != "" {
+		needle := strings.ToLower(q.Contains)
+		if !strings.Contains(strings.ToLower(dl.Title), needle) && !strings.Contains(strings.ToLower(dl.URL), needle) {
+			return false
+		}
+	}
+	if q.Text != "" {
+		have := searchWords(dl)
+		for _, want := range words(q.Text) {
+			found := false
+			for _, w := range have {
+				if strings.HasPrefix(w, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sort orders matches as q asks
+func (q Query) sort(matches []downloader.Download) error {
+	var less func(a, b *downloader.Download) bool
+	switch q.Sort {
+	case "", SortNewest:
+		less = func(a, b *downloader.Download) bool { return finishedAt(a).After(finishedAt(b)) }
+	case SortOldest:
+		less = func(a, b *downloader.Download) bool { return finishedAt(a).Before(finishedAt(b)) }
+	case SortTitle:
+		less = func(a, b *downloader.Download) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	case SortSize:
+		less = func(a, b *downloader.Download) bool { return a.FileSize > b.FileSize }
+	default:
+		return fmt.Errorf("unknown sort order: %s", q.Sort)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return less(&matches[i], &matches[j])
+	})
+	return nil
+}
+
+// paginate cuts the page q asks for out of all matches
+func (q Query) paginate(matches []downloader.Download) Page {
+	page := Page{Downloads: []downloader.Download{}, Total: len(matches)}
+	if q.Offset >= len(matches) {
+		return page
+	}
+	end := len(matches)
+	if q.Limit > 0 {
+		end = min(q.Offset+q.Limit, end)
+	}
+	page.Downloads = matches[max(q.Offset, 0):end]
+	return page
+}
+
+// RemoveFiles deletes the files of a download from disk. Files already gone
+// are not an error.
+func RemoveFiles(dl downloader.Download) error {
+	var errs []error
+	for _, path := range dl.Files() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
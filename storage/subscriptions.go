@@ -0,0 +1,413 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// DefaultSyncInterval is how often a subscription is checked unless it says otherwise
+const DefaultSyncInterval = 60 // Minutes
+
+// Subscription is a channel or playlist whose new entries are downloaded
+// automatically
+type Subscription struct {
+	ID              string                     `json:"id"`
+	URL             string                     `json:"url"`
+	Title           string                     `json:"title"`
+	Options         downloader.DownloadOptions `json:"options"`          // Applied to every entry
+	IntervalMinutes int                        `json:"interval_minutes"` // Time between scheduled syncs
+	CreatedAt       time.Time                  `json:"created_at"`
+	LastChecked     time.Time                  `json:"last_checked"`
+	LastError       string                     `json:"last_error"`
+
+	Seen   map[string]bool   `json:"seen"`   // Entry IDs downloaded (or skipped when subscribing)
+	Queued map[string]string `json:"queued"` // Download ID -> entry ID of entries in flight
+}
+
+// Due reports whether the subscription should be synced at now
+func (s *Subscription) Due(now time.Time) bool {
+	interval := s.IntervalMinutes
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	return now.Sub(s.LastChecked) >= time.Duration(interval)*time.Minute
+}
+
+// clone copies the subscription so that callers never share its maps
+func (s Subscription) clone() Subscription {
+	s.Seen = maps.Clone(s.Seen)
+	s.Queued = maps.Clone(s.Queued)
+	return s
+}
+
+// Subscriptions persists subscriptions and queues their new entries
+type Subscriptions struct {
+	Subscriptions map[string]Subscription `json:"subscriptions"`
+	LastSync      time.Time               `json:"last_sync"`
+	path          string
+	mu            sync.RWMutex
+	syncing       map[string]bool // Subscriptions with a sync in progress
+}
+
+// NewSubscriptions loads the file at path. On error the returned Subscriptions is still
+// usable, starting empty.
+func NewSubscriptions(path string) (*Subscriptions, error) {
+	s := &Subscriptions{
+		Subscriptions: make(map[string]Subscription),
+		path:          path,
+		syncing:       make(map[string]bool),
+	}
+
+	// Ensure dir exists
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+
+	if err := s.Load(); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, err
+	}
+	return s, nil
+}
+
+func (s *Subscriptions) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := loadJSON(s.path, s); err != nil {
+		return err
+	}
+	if s.Subscriptions == nil {
+		s.Subscriptions = make(map[string]Subscription)
+	}
+	for id, sub := range s.Subscriptions {
+		if sub.Seen == nil {
+			sub.Seen = make(map[string]bool)
+		}
+		if sub.Queued == nil {
+			sub.Queued = make(map[string]string)
+		}
+		s.Subscriptions[id] = sub
+	}
+	return nil
+}
+
+func (s *Subscriptions) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.LastSync = time.Now()
+	return saveJSON(s.path, s)
+}
+
+// Add saves a new subscription. Its entries are queued by the first sync.
+func (s *Subscriptions) Add(url string, opts downloader.DownloadOptions, intervalMinutes int) (Subscription, error) {
+	if url == "" {
+		return Subscription{}, fmt.Errorf("subscription URL is empty")
+	}
+	if intervalMinutes <= 0 {
+		intervalMinutes = DefaultSyncInterval
+	}
+
+	s.mu.Lock()
+	for _, sub := range s.Subscriptions {
+		if sub.URL == url {
+			s.mu.Unlock()
+			return Subscription{}, fmt.Errorf("already subscribed to %s (%s)", url, sub.ID)
+		}
+	}
+	sub := Subscription{
+		ID:              fmt.Sprintf("sub_%d", time.Now().UnixNano()),
+		URL:             url,
+		Options:         opts,
+		IntervalMinutes: intervalMinutes,
+		CreatedAt:       time.Now(),
+		Seen:            make(map[string]bool),
+		Queued:          make(map[string]string),
+	}
+	s.Subscriptions[sub.ID] = sub
+	s.mu.Unlock()
+	return sub, s.Save()
+}
+
+// Remove deletes a subscription. Entries it already queued keep downloading.
+func (s *Subscriptions) Remove(id string) error {
+	s.mu.Lock()
+	if _, ok := s.Subscriptions[id]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("subscription not found: %s", id)
+	}
+	delete(s.Subscriptions, id)
+	s.mu.Unlock()
+	return s.Save()
+}
+
+// Get returns all subscriptions, oldest first
+func (s *Subscriptions) Get() []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Subscription, 0, len(s.Subscriptions))
+	for _, sub := range s.Subscriptions {
+		list = append(list, sub.clone())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Sync lists the subscription's entries and queues those not downloaded or
+// in flight yet. It returns the IDs of the queued downloads.
+func (s *Subscriptions) Sync(ctx context.Context, dlr *downloader.Downloader, id string) ([]string, error) {
+	return s.sync(ctx, dlr, id, true)
+}
+
+// MarkExisting records every current entry as seen without downloading it,
+// so that only entries published from now on are queued
+func (s *Subscriptions) MarkExisting(ctx context.Context, dlr *downloader.Downloader, id string) error {
+	_, err := s.sync(ctx, dlr, id, false)
+	return err
+}
+
+func (s *Subscriptions) sync(ctx context.Context, dlr *downloader.Downloader, id string, queue bool) ([]string, error) {
+	s.mu.Lock()
+	sub, ok := s.Subscriptions[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("subscription not found: %s", id)
+	}
+	if s.syncing[id] {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("subscription %s is already syncing", id)
+	}
+	s.syncing[id] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.syncing, id)
+		s.mu.Unlock()
+	}()
+
+	info, entries, fetchErr := listVideos(ctx, dlr, sub.URL, downloader.DownloadOptions{
+		UseCookies:  sub.Options.UseCookies,
+		BrowserName: sub.Options.BrowserName,
+		UserAgent:   sub.Options.UserAgent,
+		ProxyURL:    sub.Options.ProxyURL,
+		Impersonate: sub.Options.Impersonate,
+	})
+
+	s.mu.Lock()
+	sub, ok = s.Subscriptions[id]
+	if !ok {
+		// Removed while listing
+		s.mu.Unlock()
+		return nil, nil
+	}
+	sub.LastChecked = time.Now()
+	sub.LastError = ""
+	if fetchErr != nil {
+		sub.LastError = fetchErr.Error()
+		if info == nil {
+			s.Subscriptions[id] = sub
+			s.mu.Unlock()
+			s.Save()
+			return nil, fetchErr
+		}
+		// Some tabs failed; the videos of the others are still synced
+		log.Printf("Subscription %s: %v", sub.URL, fetchErr)
+	}
+	if sub.Title == "" {
+		sub.Title = info.Title
+	}
+
+	inFlight := make(map[string]bool, len(sub.Queued))
+	for _, key := range sub.Queued {
+		inFlight[key] = true
+	}
+	var fresh []downloader.PlaylistEntry
+	for _, entry := range entries {
+		key := entryKey(entry)
+		if entry.URL == "" || sub.Seen[key] || inFlight[key] {
+			continue
+		}
+		if !queue {
+			sub.Seen[key] = true
+			continue
+		}
+		fresh = append(fresh, entry)
+	}
+	s.Subscriptions[id] = sub
+	opts := sub.Options
+	s.mu.Unlock()
+
+	// Entries are queued as single videos with the subscription's settings.
+	// Queueing happens outside the lock, which Finished takes.
+	opts.NoPlaylist = true
+	opts.PlaylistItems = ""
+	opts.PlaylistStart = 0
+	opts.PlaylistEnd = 0
+	ids := make([]string, len(fresh))
+	for i, entry := range fresh {
+		ids[i] = dlr.QueueDownload(entry.URL, opts)
+	}
+
+	s.mu.Lock()
+	if sub, ok = s.Subscriptions[id]; ok {
+		for i, dlID := range ids {
+			// A download that finished before it was recorded here was
+			// ignored by Finished, so settle it now
+			dl := downloader.Download{Status: dlr.GetProgress(dlID).Status}
+			sub.Queued[dlID] = entryKey(fresh[i])
+			if dl.Finished() {
+				settle(&sub, dlID, dl.Status)
+			}
+		}
+		s.Subscriptions[id] = sub
+	}
+	s.mu.Unlock()
+
+	return ids, s.Save()
+}
+
+// maxTabs bounds how many nested playlists (channel tabs) a sync lists
+const maxTabs = 10
+
+// listVideos returns the info of a subscription URL and its videos. The
+// playlists a channel's root URL lists instead of videos (its Videos, Shorts
+// and Live tabs) are listed in turn, so that new uploads keep being found.
+// When only some of those fail, the videos of the others are returned with
+// the error.
+func listVideos(ctx context.Context, dlr *downloader.Downloader, url string, opts downloader.DownloadOptions) (*downloader.VideoInfo, []downloader.PlaylistEntry, error) {
+	info, err := dlr.FetchInfo(ctx, url, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Type != "playlist" {
+		// A single video: nothing new will ever appear, but fetch it once
+		return info, []downloader.PlaylistEntry{{Index: 1, ID: info.ID, URL: url, Title: info.Title}}, nil
+	}
+
+	var videos []downloader.PlaylistEntry
+	var errs []error
+	seen := make(map[string]bool)
+	tabs := 0
+	for _, entry := range info.Entries {
+		list := []downloader.PlaylistEntry{entry}
+		if entry.IsCollection() {
+			if tabs++; tabs > maxTabs || entry.URL == "" {
+				continue
+			}
+			tab, err := dlr.FetchInfo(ctx, entry.URL, opts)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.URL, err))
+				continue
+			}
+			list = tab.Entries
+		}
+		for _, video := range list {
+			// Deeper playlists are not followed; a video can be in several tabs
+			if video.IsCollection() || seen[entryKey(video)] {
+				continue
+			}
+			seen[entryKey(video)] = true
+			videos = append(videos, video)
+		}
+	}
+	return info, videos, errors.Join(errs...)
+}
+
+// Finished records the outcome of a download queued by Sync. Completed (or
+// already downloaded) entries are never queued again; failed or cancelled
+// ones are retried by the next sync. Downloads that no subscription queued
+// are ignored.
+func (s *Subscriptions) Finished(dl downloader.Download) error {
+	if !dl.Finished() {
+		return nil
+	}
+
+	s.mu.Lock()
+	found := false
+	for id, sub := range s.Subscriptions {
+		if settle(&sub, dl.ID, dl.Status) {
+			s.Subscriptions[id] = sub
+			found = true
+		}
+	}
+	s.mu.Unlock()
+
+	if !found {
+		return nil
+	}
+	return s.Save()
+}
+
+// settle records the outcome of a finished download queued for sub, and
+// reports whether sub queued it
+func settle(sub *Subscription, dlID string, status string) bool {
+	key, ok := sub.Queued[dlID]
+	if !ok {
+		return false
+	}
+	delete(sub.Queued, dlID)
+	if status == downloader.StatusCompleted || status == downloader.StatusSkipped {
+		sub.Seen[key] = true
+	}
+	return true
+}
+
+// Due returns the subscriptions whose interval has elapsed
+func (s *Subscriptions) Due(now time.Time) []Subscription {
+	var due []Subscription
+	for _, sub := range s.Get() {
+		if sub.Due(now) {
+			due = append(due, sub)
+		}
+	}
+	return due
+}
+
+// Schedule syncs due subscriptions every minute until ctx is done
+func (s *Subscriptions) Schedule(ctx context.Context, dlr *downloader.Downloader) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		for _, sub := range s.Due(time.Now()) {
+			ids, err := s.Sync(ctx, dlr, sub.ID)
+			if err != nil {
+				log.Printf("Subscription sync failed for %s: %v", sub.URL, err)
+				continue
+			}
+			if len(ids) > 0 {
+				log.Printf("Subscription %s: queued %d new entries", sub.URL, len(ids))
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// entryKey identifies a playlist entry across syncs
+func entryKey(entry downloader.PlaylistEntry) string {
+	if entry.ID != "" {
+		return entry.ID
+	}
+	return entry.URL
+}
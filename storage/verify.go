@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shubhambadola/VidFetch/downloader"
+)
+
+// Results of checking one file of a download, see Verify
+const (
+	FileOK        = "ok"
+	FileMissing   = "missing"
+	FileMoved     = "moved"     // Gone from its path, but found elsewhere in the output folder
+	FileTruncated = "truncated" // Smaller than when it was downloaded
+	FileChanged   = "changed"   // Different size or content than when it was downloaded
+)
+
+// FileCheck is the state of one file of a download
+type FileCheck struct {
+	Path     string `json:"path"`
+	Result   string `json:"result"`
+	MovedTo  string `json:"moved_to,omitempty"`
+	Size     int64  `json:"size"`     // On disk now
+	Expected int64  `json:"expected"` // At completion, 0 when not recorded
+}
+
+// VerifyResult is the state of the files of one download
+type VerifyResult struct {
+	ID     string      `json:"id"`
+	Title  string      `json:"title"`
+	URL    string      `json:"url"`
+	Files  []FileCheck `json:"files"`
+	Broken bool        `json:"broken"` // A file is missing, truncated or changed; downloading again repairs it
+}
+
+// VerifyReport summarizes a library check
+type VerifyReport struct {
+	Checked int            `json:"checked"`
+	OK      int            `json:"ok"`
+	Moved   int            `json:"moved"`  // Entries with moved files but nothing broken
+	Broken  int            `json:"broken"` // Entries with missing, truncated or changed files
+	Results []VerifyResult `json:"results"`
+}
+
+// VerifyOptions configures Verify
+type VerifyOptions struct {
+	Quick      bool                      // Compare sizes only, without hashing file contents
+	OnProgress func(done int, total int) // Called after each download checked
+}
+
+// Verify checks that the files of every completed download in the history
+// still exist with the size and content recorded at completion. Files gone
+// from their path are looked for in the download's output folder by size
+// and hash. The report lists the downloads with a moved or broken file.
+func Verify(ctx context.Context, hist HistoryStore, opts VerifyOptions) (VerifyReport, error) {
+	report := VerifyReport{Results: []VerifyResult{}}
+	page, err := hist.Query(Query{Status: downloader.StatusCompleted})
+	if err != nil {
+		return report, err
+	}
+
+	finder := newFileFinder()
+	for i, dl := range page.Downloads {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if dl.IsCollection() || len(dl.Files()) == 0 {
+			continue
+		}
+
+		result := verifyDownload(dl, opts.Quick, finder)
+		report.Checked++
+		moved := false
+		for _, f := range result.Files {
+			moved = moved || f.Result == FileMoved
+		}
+		switch {
+		case result.Broken:
+			report.Broken++
+			report.Results = append(report.Results, result)
+		case moved:
+			report.Moved++
+			report.Results = append(report.Results, result)
+		default:
+			report.OK++
+		}
+
+		if opts.OnProgress != nil {
+			opts.OnProgress(i+1, len(page.Downloads))
+		}
+	}
+	return report, nil
+}
+
+// verifyDownload checks each file of dl against its checksum. Downloads from
+// before checksums were recorded are checked for existence, and the size of
+// a single media file against FileSize.
+func verifyDownload(dl downloader.Download, quick bool, finder *fileFinder) VerifyResult {
+	sums := dl.Checksums
+	if len(sums) == 0 {
+		for _, path := range dl.Files() {
+			sum := downloader.Checksum{Path: path}
+			if len(dl.MediaFiles) <= 1 && path == dl.FilePath {
+				sum.Size = dl.FileSize
+			}
+			sums = append(sums, sum)
+		}
+	}
+
+	result := VerifyResult{ID: dl.ID, Title: dl.Title, URL: dl.URL}
+	for _, sum := range sums {
+		check := checkFile(sum, quick)
+		if check.Result == FileMissing {
+			if moved := finder.find(sum, quick, dl.Options.OutputDir, filepath.Dir(sum.Path)); moved != "" {
+				check.Result = FileMoved
+				check.MovedTo = moved
+			}
+		}
+		if check.Result != FileOK && check.Result != FileMoved {
+			result.Broken = true
+		}
+		result.Files = append(result.Files, check)
+	}
+	return result
+}
+
+func checkFile(sum downloader.Checksum, quick bool) FileCheck {
+	check := FileCheck{Path: sum.Path, Expected: sum.Size}
+	fi, err := os.Stat(sum.Path)
+	if err != nil {
+		check.Result = FileMissing
+		return check
+	}
+	check.Size = fi.Size()
+
+	switch {
+	case sum.Size > 0 && check.Size < sum.Size:
+		check.Result = FileTruncated
+	case sum.Size > 0 && check.Size != sum.Size:
+		check.Result = FileChanged
+	case !quick && sum.SHA256 != "" && !sameContent(sum.Path, sum):
+		check.Result = FileChanged
+	default:
+		check.Result = FileOK
+	}
+	return check
+}
+
+func sameContent(path string, sum downloader.Checksum) bool {
+	actual, err := downloader.FileChecksum(path)
+	return err == nil && actual.SHA256 == sum.SHA256
+}
+
+// fileFinder looks for moved files by size, listing each folder once per
+// Verify
+type fileFinder struct {
+	bySize map[string]map[int64][]string // Folder -> size -> paths
+}
+
+func newFileFinder() *fileFinder {
+	return &fileFinder{bySize: make(map[string]map[int64][]string)}
+}
+
+// find returns a file under one of dirs that matches sum: same size and,
+// when hashing, same content, otherwise the same extension. Returns "" when
+// there is none or the size was not recorded.
+func (f *fileFinder) find(sum downloader.Checksum, quick bool, dirs ...string) string {
+	if sum.Size <= 0 {
+		return ""
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		for _, path := range f.list(dir)[sum.Size] {
+			if !quick && sum.SHA256 != "" {
+				if sameContent(path, sum) {
+					return path
+				}
+			} else if strings.EqualFold(filepath.Ext(path), filepath.Ext(sum.Path)) {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+func (f *fileFinder) list(dir string) map[int64][]string {
+	dir = filepath.Clean(dir)
+	if sizes, ok := f.bySize[dir]; ok {
+		return sizes
+	}
+	sizes := make(map[int64][]string)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			sizes[info.Size()] = append(sizes[info.Size()], path)
+		}
+		return nil
+	})
+	f.bySize[dir] = sizes
+	return sizes
+}
+
+// SetAsideBroken renames the truncated and changed files of a verified
+// download to <path>.broken, so that downloading it again does not skip
+// them as already present
+func SetAsideBroken(result VerifyResult) error {
+	var errs []error
+	for _, f := range result.Files {
+		if f.Result != FileTruncated && f.Result != FileChanged {
+			continue
+		}
+		if err := os.Rename(f.Path, f.Path+".broken"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}